@@ -1,61 +1,225 @@
 package album
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
-
-	"github.com/kr/pretty"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nanoteck137/slurpuff/audio"
+	"github.com/nanoteck137/slurpuff/cover"
+	"github.com/nanoteck137/slurpuff/destination"
+	"github.com/nanoteck137/slurpuff/id3"
+	"github.com/nanoteck137/slurpuff/mp4"
+	"github.com/nanoteck137/slurpuff/progress"
+	"github.com/nanoteck137/slurpuff/types"
 	"github.com/nanoteck137/slurpuff/utils"
 	"github.com/pelletier/go-toml/v2"
 )
 
-type Track struct {
-	Filename  string   `toml:"filename"`
-	Num       int      `toml:"num"`
-	Name      string   `toml:"name"`
-	Artist    string   `toml:"artist"`
-	Date      string   `toml:"date"`
-	Tags      []string `toml:"tags"`
-	Genres    []string `toml:"genres"`
-	Featuring []string `toml:"featuring"`
+const DefaultVariousArtists = "Various Artists"
+
+type Options struct {
+	Mode string
+
+	// Output path template, uses the default template for the mode if
+	// empty
+	Template string
+
+	// Name used for the artist folder and the album artist tag when
+	// exporting compilations
+	VariousArtists string
+
+	// Sanitize profile used for the output names, uses
+	// utils.DefaultSanitizeProfile if empty
+	Sanitize string
+
+	// Maximum length in characters of an output path relative to the
+	// destination, 0 means no limit
+	MaxPathLength int
+
+	// Allow transcoding lossy sources to another lossy codec
+	AllowLossyTranscode bool
+
+	// Resampler used when converting the sample rate or bit depth, uses
+	// DefaultResampler if empty
+	Resampler string
+
+	// Maximum sample rate and bit depth of the output, 0 keeps the limits
+	// of the mode. The limits can only lower the limits of the transcoding
+	// modes, dwebble and map converts the lossless sources over the limits
+	// to flac
+	MaxSampleRate int
+	MaxBitDepth   int
+
+	// Processing of the cover art copies and the embedded cover
+	Cover cover.Options
+
+	// Artwork types copied to the album folder, all types is copied if
+	// empty
+	ArtworkTypes []string
+
+	// Progress of the tracks is reported to the tracker, uses a plain
+	// tracker writing to stdout if nil
+	Progress *progress.Tracker
+
+	// Destination the album is written to, dst is a path inside the
+	// destination. Uses the local filesystem if nil
+	Destination destination.Destination
 }
 
-type AlbumConfig struct {
-	Album    string  `toml:"album"`
-	Artist   string  `toml:"artist"`
-	CoverArt string  `toml:"coverart"`
-	Tracks   []Track `toml:"tracks"`
+func (o Options) progress() *progress.Tracker {
+	if o.Progress == nil {
+		return progress.New(os.Stdout, progress.ModePlain)
+	}
+
+	return o.Progress
 }
 
-func Execute(mode, src, dst string) error {
-	// TODO(patrik): Add force flag
+func (o Options) destination() destination.Destination {
+	if o.Destination == nil {
+		return destination.Local{}
+	}
 
-	err := os.MkdirAll(dst, 0755)
-	if err != nil {
-		return err
+	return o.Destination
+}
+
+// SanitizeProfile returns the sanitize profile used for the output names
+func (o Options) SanitizeProfile() string {
+	if o.Sanitize == "" {
+		return utils.DefaultSanitizeProfile
+	}
+
+	return o.Sanitize
+}
+
+func (o Options) resampler() string {
+	if o.Resampler == "" {
+		return DefaultResampler
+	}
+
+	return o.Resampler
+}
+
+func (o Options) variousArtists() string {
+	if o.VariousArtists == "" {
+		return DefaultVariousArtists
+	}
+
+	return o.VariousArtists
+}
+
+// upgradeOldConfig converts the old album.toml format (tracks with
+// filename and date) so albums not yet converted can still be exported
+func upgradeOldConfig(old types.OldAlbumMetadata) (types.AlbumMetadata, error) {
+	config := types.AlbumMetadata{
+		Album:    old.Album,
+		Artist:   old.Artist,
+		CoverArt: old.CoverArt,
 	}
 
+	for _, t := range old.Tracks {
+		year := 0
+		if t.Date != "" {
+			y, err := types.ParseYear(t.Date)
+			if err != nil {
+				return types.AlbumMetadata{}, fmt.Errorf("track %d (%s): %w", t.Num, t.Name, err)
+			}
+
+			year = y
+		}
+
+		file := types.TrackFile{
+			Lossless: t.Filename,
+		}
+
+		if utils.IsLossyFormatExt(path.Ext(t.Filename)) {
+			file = types.TrackFile{
+				Lossy: t.Filename,
+			}
+		}
+
+		config.Tracks = append(config.Tracks, types.TrackMetadata{
+			Num:       t.Num,
+			Name:      t.Name,
+			Artist:    t.Artist,
+			Year:      year,
+			Tags:      t.Tags,
+			Genres:    t.Genres,
+			Featuring: t.Featuring,
+			File:      file,
+		})
+	}
+
+	return config, nil
+}
+
+// ReadConfig reads the album.toml inside src, configs in the old format is
+// upgraded
+func ReadConfig(src string) (types.AlbumMetadata, error) {
 	conf := path.Join(src, "album.toml")
 
 	data, err := os.ReadFile(conf)
 	if err != nil {
-		return fmt.Errorf("%s: %w", conf, err)
+		return types.AlbumMetadata{}, fmt.Errorf("%s: %w", conf, err)
 	}
 
-	var config AlbumConfig
+	var config types.AlbumMetadata
 	err = toml.Unmarshal(data, &config)
 	if err != nil {
-		return fmt.Errorf("%s: %w", conf, err)
+		return types.AlbumMetadata{}, fmt.Errorf("%s: %w", conf, err)
+	}
+
+	// NOTE(patrik): The old format has the file as "filename" on the
+	// tracks, the tracks of the new format always has a "file" table
+	var old types.OldAlbumMetadata
+	err = toml.Unmarshal(data, &old)
+	if err != nil {
+		return types.AlbumMetadata{}, fmt.Errorf("%s: %w", conf, err)
+	}
+
+	for _, track := range old.Tracks {
+		if track.Filename == "" {
+			continue
+		}
+
+		slog.Warn("Old album.toml format, run 'slurpuff convert' to update it", "file", conf)
+
+		config, err = upgradeOldConfig(old)
+		if err != nil {
+			return types.AlbumMetadata{}, fmt.Errorf("%s: %w", conf, err)
+		}
+
+		break
 	}
 
-	err = ExecuteConfig(config, mode, src, dst)
+	return config, nil
+}
+
+func Execute(opts Options, src, dst string) error {
+	// TODO(patrik): Add force flag
+
+	err := opts.destination().MkdirAll(dst)
 	if err != nil {
-		return fmt.Errorf("%s: %w", conf, err)
+		return err
+	}
+
+	config, err := ReadConfig(src)
+	if err != nil {
+		return err
+	}
+
+	err = ExecuteConfig(config, opts, src, dst)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path.Join(src, "album.toml"), err)
 	}
 
 	return nil
@@ -66,176 +230,765 @@ const (
 	ModeOpus    = "opus"
 	ModeMp3     = "mp3"
 	ModeMap     = "map"
+	ModeAac     = "aac"
+
+	// Lossless for devices that can't play hi-res, 16-bit/44.1 kHz flac
+	ModeLosslessPortable = "lossless-portable"
 )
 
-func ExecuteConfig(config AlbumConfig, mode, src, dst string) error {
-	artistName := strings.TrimSpace(config.Artist)
+func IsValidMode(mode string) bool {
+	switch mode {
+	case ModeDwebble, ModeOpus, ModeMp3, ModeMap, ModeAac, ModeLosslessPortable:
+		return true
+	}
 
-	safeArtistName, err := utils.SafeName(artistName)
-	if err != nil {
-		return err
+	return false
+}
+
+func trackTemplateValues(config types.AlbumMetadata, track types.TrackMetadata, artistName, ext string) map[string]string {
+	trackArtist := track.Artist
+	if trackArtist == "" {
+		trackArtist = config.Artist
+	}
+
+	values := map[string]string{
+		"artist":       artistName,
+		"track_artist": trackArtist,
+		"album":        config.Album,
+		"num":          strconv.Itoa(track.Num),
+		"title":        track.Name,
+		"ext":          ext,
+		"type":         config.ReleaseType(),
+	}
+
+	// NOTE(patrik): The sort and alternate forms falls back to the normal
+	// name so templates using them always produce a name
+	artistSort, artistAlt := "", ""
+	if artistName == config.Artist {
+		artistSort, artistAlt = config.ArtistSort, config.ArtistAlt
+	}
+
+	trackArtistSort, trackArtistAlt := track.ArtistSort, track.ArtistAlt
+	if track.Artist == "" {
+		trackArtistSort, trackArtistAlt = config.ArtistSort, config.ArtistAlt
+	}
+
+	alternatives := []struct {
+		field string
+		value string
+		base  string
+	}{
+		{"artist_sort", artistSort, types.DefaultSortName(artistName)},
+		{"artist_alt", artistAlt, artistName},
+		{"track_artist_sort", trackArtistSort, types.DefaultSortName(trackArtist)},
+		{"track_artist_alt", trackArtistAlt, trackArtist},
+		{"album_sort", config.AlbumSort, types.DefaultSortName(config.Album)},
+		{"album_alt", config.AlbumAlt, config.Album},
+		{"title_sort", track.NameSort, types.DefaultSortName(track.Name)},
+		{"title_alt", track.NameAlt, track.Name},
+	}
+
+	for _, alt := range alternatives {
+		values[alt.field] = alt.value
+		if alt.value == "" {
+			values[alt.field] = alt.base
+		}
+	}
+
+	if track.Year != 0 {
+		values["year"] = strconv.Itoa(track.Year)
+	}
+
+	if track.Disc != 0 {
+		values["disc"] = strconv.Itoa(track.Disc)
+	}
+
+	if len(track.Genres) > 0 {
+		values["genre"] = track.Genres[0]
+	}
+
+	for k, v := range values {
+		values[k] = strings.TrimSpace(v)
+	}
+
+	return values
+}
+
+const (
+	dirKindNone = iota
+	dirKindArtist
+	dirKindAlbum
+)
+
+type outputDir struct {
+	path string
+	kind int
+}
+
+type trackJob struct {
+	input  string
+	output string
+	ext    string
+	args   []string
+
+	// Duration of the track used for the progress, 0 if unknown
+	duration time.Duration
+
+	// Tags written after ffmpeg is done, ffmpeg can't write them
+	freeform  []mp4.Tag
+	id3Frames []id3.Frame
+
+	// Write iTunSMPB from the edit list, only set for tracks encoded to
+	// AAC so the delay and padding is the real values of the encoder
+	itunSMPB bool
+}
+
+// exportPlan is the output of planExport, everything needed to write the
+// album to the destination
+type exportPlan struct {
+	jobs        []trackJob
+	dirs        []outputDir
+	transcoding bool
+
+	artistName string
+	artistSort string
+	albumName  string
+	albumSort  string
+}
+
+// planExport validates the options and creates the jobs for the tracks,
+// nothing is written to dst
+func planExport(config types.AlbumMetadata, opts Options, src, dst string) (exportPlan, error) {
+	mode := opts.Mode
+
+	if !IsValidMode(mode) {
+		return exportPlan{}, fmt.Errorf("unknown mode: %s", mode)
+	}
+
+	if config.Type != "" && !types.IsValidReleaseType(config.Type) {
+		return exportPlan{}, fmt.Errorf("unknown release type: %s", config.Type)
 	}
 
-	dstDir := path.Join(dst, safeArtistName)
-	err = os.MkdirAll(dstDir, 0755)
+	templateSource := opts.Template
+	if templateSource == "" {
+		templateSource = DefaultTemplate
+	}
+
+	template, err := ParseTemplate(templateSource)
 	if err != nil {
-		return err
+		return exportPlan{}, err
 	}
 
-	if artistName != safeArtistName {
-		err := os.WriteFile(path.Join(dstDir, "override.txt"), []byte(artistName), 0644)
-		if err != nil {
-			return err
+	profile := opts.SanitizeProfile()
+	if !utils.IsValidSanitizeProfile(profile) {
+		return exportPlan{}, fmt.Errorf("unknown sanitize profile: %s", profile)
+	}
+
+	for _, kind := range opts.ArtworkTypes {
+		if !types.IsValidArtworkType(kind) {
+			return exportPlan{}, fmt.Errorf("unknown artwork type: %s", kind)
 		}
 	}
 
-	pretty.Println(config)
+	resampler := opts.resampler()
+	if !IsValidResampler(resampler) {
+		return exportPlan{}, fmt.Errorf("unknown resampler: %s", resampler)
+	}
+
+	if opts.MaxSampleRate < 0 {
+		return exportPlan{}, fmt.Errorf("invalid max sample rate: %d", opts.MaxSampleRate)
+	}
+
+	if !IsValidBitDepth(opts.MaxBitDepth) {
+		return exportPlan{}, fmt.Errorf("invalid max bit depth: %d (16 or 24)", opts.MaxBitDepth)
+	}
+
+	artistName := strings.TrimSpace(config.Artist)
+
+	// NOTE(patrik): Compilations is grouped together under one artist
+	// folder, the track artists is still written to the tracks
+	if config.IsCompilation() || artistName == "" {
+		artistName = opts.variousArtists()
+	}
 
 	albumName := config.Album
+	albumSort := config.AlbumSort
+	if albumSort == "" {
+		albumSort = types.DefaultSortName(albumName)
+	}
 
-	safeAlbumName, err := utils.SafeName(albumName)
-	if err != nil {
-		return err
+	artistSort := types.DefaultSortName(artistName)
+	if artistName == config.Artist && config.ArtistSort != "" {
+		artistSort = config.ArtistSort
 	}
 
-	dir := path.Join(dstDir, safeAlbumName)
-	err = os.MkdirAll(dir, 0755)
-	if err != nil {
-		return err
+	encoder, transcoding := encodeProfiles[mode]
+
+	limited := opts.MaxSampleRate != 0 || opts.MaxBitDepth != 0
+	if !transcoding && limited {
+		encoder = limitProfile
 	}
 
-	if albumName != safeAlbumName {
-		// TODO(patrik): Dont override
-		err := os.WriteFile(path.Join(dir, "override.txt"), []byte(albumName), 0644)
-		if err != nil {
-			return err
+	encoder = encoder.limit(opts.MaxSampleRate, opts.MaxBitDepth)
+
+	// NOTE(patrik): Probe all the sources up front, gapless albums needs
+	// to know the sample rate of every track
+	infos := make(map[string]utils.Info)
+	if transcoding || limited {
+		for _, track := range config.Tracks {
+			filename := track.SourceFile()
+			if filename == "" {
+				continue
+			}
+
+			p := path.Join(src, filename)
+			if _, exists := infos[p]; exists {
+				continue
+			}
+
+			info, err := utils.GetInfo(p)
+			if err != nil {
+				return exportPlan{}, fmt.Errorf("%s: %w", p, err)
+			}
+
+			infos[p] = info
 		}
 	}
 
-	coverArt := ""
-	if config.CoverArt != "" {
-		srcCoverArt := path.Join(src, config.CoverArt)
-		ext := path.Ext(srcCoverArt)
-		coverArt = path.Join(dir, "cover"+ext)
-		_, err = utils.Copy(srcCoverArt, coverArt)
-		if err != nil {
-			return err
+	// NOTE(patrik): Players reopens the output when the sample rate
+	// changes between tracks, gapless albums is encoded with the same rate
+	// for every track
+	if transcoding && config.Gapless && encoder.sampleRate == 0 {
+		for _, info := range infos {
+			if info.IsLossless() && info.SampleRate != 0 {
+				encoder.sampleRate = max(encoder.sampleRate, encoder.outputSampleRate(info.SampleRate))
+			}
 		}
 	}
 
-	wg := sync.WaitGroup{}
+	var jobs []trackJob
+	var dirs []outputDir
+	seenDirs := make(map[string]bool)
 
-	plock := sync.Mutex{}
+	var problems []string
+	seenOutputs := make(map[string]string)
 
-	// TODO(patrik): Check albumName for forward slashes and other illegal
-	// filesystem characters
 	for _, track := range config.Tracks {
 		args := []string{}
 
-		trackPath := path.Join(src, track.Filename)
+		// TODO(patrik): Should we let the user choose between lossless and lossy?
+		filename := track.SourceFile()
+		if filename == "" {
+			return exportPlan{}, fmt.Errorf("track %d (%s) has no file", track.Num, track.Name)
+		}
+
+		if track.File.IsSplit() && track.File.Lossless == "" {
+			return exportPlan{}, fmt.Errorf("track %d (%s) has a range but no lossless file", track.Num, track.Name)
+		}
+
+		trackPath := path.Join(src, filename)
 
 		inputExt := path.Ext(trackPath)
 		outputExt := ""
 
+		copyMode := false
+		var filters []string
+		var encodeArgs []string
+
 		switch mode {
 		case ModeDwebble:
+			// NOTE(patrik): Dwebble is the archive, hi-res sources is
+			// kept as is
 			outputExt = inputExt
 			if inputExt == ".wav" {
 				outputExt = ".flac"
 			}
-		case ModeMp3:
-			// TODO(patrik): Add params
-			outputExt = ".mp3"
-		case ModeOpus:
-			// TODO(patrik): Add params
-			outputExt = ".opus"
+
+			copyMode = inputExt == outputExt
+		case ModeMp3, ModeOpus, ModeAac, ModeLosslessPortable:
+			info := infos[trackPath]
+
+			plan, err := encoder.plan(info, opts.AllowLossyTranscode, resampler)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("'%s': %v", filename, err))
+			} else if !plan.copy && !info.IsLossless() {
+				slog.Warn("Transcoding lossy source", "file", filename, "codec", info.Codec, "bitrate", kbps(info.BitRate), "to", encoder.codec)
+			}
+
+			outputExt = plan.ext
+			if plan.copy {
+				outputExt = inputExt
+			}
+
+			copyMode = plan.copy
+			filters = plan.filters
+			encodeArgs = plan.args
 		case ModeMap:
 			outputExt = inputExt
-		default:
-			log.Fatal("Unknown mode:", mode)
+			copyMode = true
+		}
+
+		// NOTE(patrik): Lossy sources is kept as is, converting them would
+		// only lose quality
+		if !transcoding && limited {
+			info := infos[trackPath]
+			if info.IsLossless() && !encoder.withinLimits(info) {
+				plan := encoder.encode(info, 0, resampler)
+
+				outputExt = plan.ext
+				copyMode = false
+				filters = plan.filters
+				encodeArgs = plan.args
+			}
+		}
+
+		// NOTE(patrik): Split tracks can't be stream copied and still be
+		// sample accurate, the sources is always lossless so they are
+		// encoded as flac instead
+		if track.File.IsSplit() {
+			if copyMode || outputExt == ".wav" {
+				copyMode = false
+				outputExt = ".flac"
+				encodeArgs = []string{"-compression_level", "8"}
+			}
+
+			filters = append([]string{audio.TrimFilter(track.File.Start, track.File.End)}, filters...)
 		}
 
-		copyMode := inputExt == outputExt
+		// NOTE(patrik): The inputs, maps and output is added by
+		// trackJob.ffmpegArgs
+		args = append(args, "-map_metadata", "-1")
+
+		if len(filters) > 0 {
+			args = append(args, "-af", strings.Join(filters, ","))
+		}
 
-		args = append(args, "-i", trackPath, "-vn", "-map_metadata", "-1")
+		tags := buildTrackTags(config, track, artistName)
+		args = append(args, tags.args(containerForExt(outputExt))...)
+		freeform := tags.freeform(containerForExt(outputExt))
+		id3Frames := tags.id3Frames(containerForExt(outputExt))
+
+		// NOTE(patrik): ffmpeg writes the edit list in the movie timescale
+		// (1000 by default) rounded up, the iTunSMPB written from it is only
+		// sample accurate if the timescale is the sample rate
+		if outputExt == ".m4a" {
+			info, exists := infos[trackPath]
+			if !exists {
+				info, err = utils.GetInfo(trackPath)
+				if err != nil {
+					return exportPlan{}, fmt.Errorf("%s: %w", trackPath, err)
+				}
 
-		artist := track.Artist
-		if artist == "" {
-			artist = config.Artist
+				infos[trackPath] = info
+			}
+
+			sampleRate := info.SampleRate
+			if transcoding && !copyMode {
+				sampleRate = encoder.outputSampleRate(info.SampleRate)
+			}
+
+			if sampleRate != 0 {
+				args = append(args, "-movie_timescale", strconv.Itoa(sampleRate))
+			}
 		}
 
-		args = append(args, "-metadata", fmt.Sprintf("title=%s", track.Name))
-		args = append(args, "-metadata", fmt.Sprintf("artist=%s", artist))
-		args = append(args, "-metadata", fmt.Sprintf("album_artist=%s", config.Artist))
-		args = append(args, "-metadata", fmt.Sprintf("album=%s", albumName))
-		args = append(args, "-metadata", fmt.Sprintf("track=%d", track.Num))
+		if copyMode {
+			args = append(args, "-codec", "copy")
+		} else {
+			args = append(args, encodeArgs...)
+		}
 
-		if len(track.Tags) > 0 {
-			args = append(args, "-metadata", fmt.Sprintf("tags=%s", strings.Join(track.Tags, ",")))
+		values := trackTemplateValues(config, track, artistName, outputExt)
+		segments, err := template.Execute(values)
+		if err != nil {
+			return exportPlan{}, err
 		}
 
-		if track.Date != "" {
-			args = append(args, "-metadata", fmt.Sprintf("date=%s", track.Date))
+		output := dst
+		for i, segment := range segments {
+			ext := ""
+			isFile := i == len(segments)-1
+			if isFile && strings.HasSuffix(segment, outputExt) {
+				ext = outputExt
+			}
+
+			safeSegment, err := utils.SafeNameWithProfile(segment, ext, profile)
+			if err != nil {
+				return exportPlan{}, err
+			}
+
+			output = path.Join(output, safeSegment)
+
+			isDir := i < len(segments)-1
+			if isDir && !seenDirs[output] {
+				seenDirs[output] = true
+
+				kind := dirKindNone
+				switch {
+				case template.SegmentUses(i, "album"):
+					kind = dirKindAlbum
+				case template.SegmentUses(i, "artist"):
+					kind = dirKindArtist
+				}
+
+				dirs = append(dirs, outputDir{
+					path: output,
+					kind: kind,
+				})
+			}
 		}
 
-		if len(track.Featuring) > 0 {
-			args = append(args, "-metadata", fmt.Sprintf("featuring=%s", strings.Join(track.Featuring, ",")))
+		rel := strings.TrimPrefix(strings.TrimPrefix(output, path.Clean(dst)), "/")
+		if opts.MaxPathLength > 0 && utf8.RuneCountInString(rel) > opts.MaxPathLength {
+			problems = append(problems, fmt.Sprintf("'%s' is longer than %d characters", rel, opts.MaxPathLength))
 		}
 
-		if len(track.Genres) > 0 {
-			args = append(args, "-metadata", fmt.Sprintf("genre=%s", strings.Join(track.Genres, ",")))
+		key := utils.NameKey(output, profile)
+		if other, exists := seenOutputs[key]; exists {
+			problems = append(problems, fmt.Sprintf("'%s' and '%s' both map to '%s'", other, filename, rel))
 		}
+		seenOutputs[key] = filename
 
-		if !copyMode && outputExt == ".opus" {
-			args = append(args, "-vbr", "on", "-b:a", "128k")
+		duration := track.Duration
+		if info, exists := infos[trackPath]; duration == 0 && exists && !track.File.IsSplit() {
+			duration = info.Duration
 		}
 
-		if copyMode {
-			args = append(args, "-codec", "copy")
+		// NOTE(patrik): Stream copies (ALAC, AAC sources in map or
+		// dwebble mode) has no encoder delay of ours to describe, only
+		// gapless albums encoded to AAC gets iTunSMPB. The other tracks
+		// still has the edit list written by ffmpeg.
+		itunSMPB := config.Gapless && transcoding && !copyMode && encoder.codec == "aac" && outputExt == ".m4a"
+
+		jobs = append(jobs, trackJob{
+			input:     trackPath,
+			output:    output,
+			ext:       outputExt,
+			args:      args,
+			duration:  time.Duration(duration) * time.Second,
+			freeform:  freeform,
+			id3Frames: id3Frames,
+			itunSMPB:  itunSMPB,
+		})
+	}
+
+	// NOTE(patrik): Report the problems before anything is written so we
+	// don't end up with half exported albums
+	if len(problems) > 0 {
+		return exportPlan{}, fmt.Errorf("export problems:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return exportPlan{
+		jobs:        jobs,
+		dirs:        dirs,
+		transcoding: transcoding,
+		artistName:  artistName,
+		artistSort:  artistSort,
+		albumName:   albumName,
+		albumSort:   albumSort,
+	}, nil
+}
+
+// Outputs returns the paths of the tracks the export of the album writes
+func Outputs(config types.AlbumMetadata, opts Options, src, dst string) ([]string, error) {
+	plan, err := planExport(config, opts, src, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]string, 0, len(plan.jobs))
+	for _, job := range plan.jobs {
+		outputs = append(outputs, job.output)
+	}
+
+	return outputs, nil
+}
+
+// AlbumDir returns the folder containing all the tracks of the album, dst
+// itself for flat layouts
+func AlbumDir(outputs []string) string {
+	jobs := make([]trackJob, 0, len(outputs))
+	for _, output := range outputs {
+		jobs = append(jobs, trackJob{output: output})
+	}
+
+	return commonDir(jobs)
+}
+
+func ExecuteConfig(config types.AlbumMetadata, opts Options, src, dst string) error {
+	slog.Info("Exporting album", "artist", config.Artist, "album", config.Album, "mode", opts.Mode)
+
+	plan, err := planExport(config, opts, src, dst)
+	if err != nil {
+		return err
+	}
+
+	jobs := plan.jobs
+	d := opts.destination()
+
+	err = d.MkdirAll(dst)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range plan.dirs {
+		err := d.MkdirAll(dir.path)
+		if err != nil {
+			return err
 		}
+	}
+
+	// NOTE(patrik): The cover and the artwork is produced locally and
+	// written to the album folder when done
+	staging, err := os.MkdirTemp("", "slurpuff-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	// NOTE(patrik): Flat layouts don't have an album folder to put the
+	// cover and the artwork inside
+	albumDir := commonDir(jobs)
+	if albumDir == path.Clean(dst) {
+		albumDir = ""
+	}
+
+	// NOTE(patrik): Every output is written with a temporary name and
+	// renamed when done, the files left by interrupted exports is removed
+	// here
+	tempDirs := []string{dst}
+	for _, dir := range plan.dirs {
+		tempDirs = append(tempDirs, dir.path)
+	}
+
+	if albumDir != "" {
+		tempDirs = append(tempDirs, path.Join(albumDir, "artwork"))
+	}
 
-		outputName := fmt.Sprintf("%02v - %s%s", track.Num, strings.TrimSpace(track.Name), outputExt)
-		safeOutputName, err := utils.SafeName(outputName)
+	for _, dir := range tempDirs {
+		err := destination.RemoveTempFiles(d, dir)
 		if err != nil {
 			return err
 		}
+	}
 
-		output := path.Join(dir, safeOutputName)
-		args = append(args, output)
+	coverArt := ""
+	coverArtDst := ""
+	if config.CoverArt != "" {
+		coverDir := ""
+		if albumDir != "" {
+			coverDir = staging
+		}
 
-		wg.Add(1)
+		res, err := cover.Process(path.Join(src, config.CoverArt), coverDir, opts.Cover)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(res.Embed)
 
-		go func() {
-			plock.Lock()
-			fmt.Println("Processing:", trackPath)
-			plock.Unlock()
+		coverArt = res.Embed
+		if res.Cover != "" {
+			coverArtDst = path.Join(albumDir, path.Base(res.Cover))
+		}
+	}
+
+	// NOTE(patrik): Only the transcoding modes embeds the artwork, the
+	// other modes is for servers that reads the artwork folder
+	var pictures []picture
+	if plan.transcoding && coverArt != "" {
+		pictures = append(pictures, picture{
+			kind: types.ArtworkFront,
+			path: coverArt,
+		})
+	}
+
+	if plan.transcoding && embedsArtwork(jobs) {
+		embedded, err := embedPictures(config, src, opts.Cover)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			for _, pic := range embedded {
+				os.Remove(pic.path)
+			}
+		}()
+
+		pictures = append(pictures, embedded...)
+	}
+
+	if albumDir != "" {
+		err := copyArtwork(config, src, staging, opts.ArtworkTypes, opts.Cover)
+		if err != nil {
+			return err
+		}
+
+		err = destination.PutDir(d, staging, albumDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range plan.dirs {
+		var metadata any
+
+		switch dir.kind {
+		case dirKindArtist:
+			altName := ""
+			if plan.artistName == config.Artist {
+				altName = config.ArtistAlt
+			}
+
+			metadata = types.ArtistFolderMetadata{
+				Name:     plan.artistName,
+				SortName: plan.artistSort,
+				AltName:  altName,
+			}
+		case dirKindAlbum:
+			coverName := ""
+			if coverArtDst != "" && strings.HasPrefix(coverArtDst, dir.path+"/") {
+				coverName = strings.TrimPrefix(coverArtDst, dir.path+"/")
+			}
+
+			metadata = types.AlbumFolderMetadata{
+				Name:     plan.albumName,
+				SortName: plan.albumSort,
+				AltName:  config.AlbumAlt,
+				Artist:   plan.artistName,
+				Year:     config.Year(),
+				Type:     config.ReleaseType(),
+				CoverArt: coverName,
+			}
+		default:
+			continue
+		}
+
+		data, err := toml.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		err = destination.WriteFileIfChanged(d, path.Join(dir.path, types.FolderMetadataFile), data)
+		if err != nil {
+			return err
+		}
+
+		// NOTE(patrik): Remove the old override files, the name is now
+		// stored inside the metadata file
+		err = d.Remove(path.Join(dir.path, "override.txt"))
+		if err != nil {
+			return err
+		}
+	}
 
-			cmd := exec.Command("ffmpeg", args...)
-			// cmd.Stdout = os.Stdout
-			// cmd.Stderr = os.Stderr
+	tracker := opts.progress()
 
-			err = cmd.Run()
+	process := func(job trackJob, task *progress.Task) error {
+		staged, err := d.Stage(job.output)
+		if err != nil {
+			return err
+		}
+		defer staged.Discard()
+
+		cmd := exec.Command("ffmpeg", job.ffmpegArgs(pictures, staged.Path)...)
+		stderr := utils.CaptureStderr(cmd)
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+
+		err = cmd.Start()
+		if err != nil {
+			return err
+		}
+
+		err = progress.Parse(stdout, task.Update)
+		if err != nil {
+			slog.Warn("Failed to read ffmpeg progress", "file", job.output, "err", err)
+
+			// NOTE(patrik): ffmpeg blocks if nobody reads the pipe
+			io.Copy(io.Discard, stdout)
+		}
+
+		err = stderr.Done(cmd.Wait())
+		if err != nil {
+			return err
+		}
+
+		if job.ext == ".m4a" && (len(job.freeform) > 0 || job.itunSMPB) {
+			err := mp4.WriteTags(staged.Path, job.freeform, job.itunSMPB)
+			if err != nil {
+				return err
+			}
+		}
+
+		if job.ext == ".mp3" && len(job.id3Frames) > 0 {
+			err := id3.WriteTextFrames(staged.Path, job.id3Frames)
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
+		}
 
-			if outputExt == ".opus" && coverArt != "" {
-				cmd := exec.Command("opusimage", output, coverArt)
-				err := cmd.Run()
-				if err != nil {
-					log.Fatal(err)
-				}
+		if job.ext == ".opus" && coverArt != "" {
+			cmd := exec.Command("opusimage", staged.Path, coverArt)
+			stderr := utils.CaptureStderr(cmd)
+
+			err := stderr.Done(cmd.Run())
+			if err != nil {
+				return err
 			}
+		}
 
-			plock.Lock()
-			fmt.Println("Done Processing:", trackPath)
-			plock.Unlock()
+		return staged.Commit()
+	}
 
-			wg.Done()
+	wg := sync.WaitGroup{}
+
+	plock := sync.Mutex{}
+
+	// NOTE(patrik): The errors is collected so long running commands
+	// (watch, sync) can continue with the next album
+	var errs []error
+
+	for _, job := range jobs {
+		job := job
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			task := tracker.Start(path.Base(job.output), job.duration)
+
+			err := process(job, task)
+			task.Done(err)
+
+			if err != nil {
+				plock.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", job.input, err))
+				plock.Unlock()
+			}
 		}()
 	}
 
 	wg.Wait()
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// commonDir returns the deepest directory shared by all the job outputs
+func commonDir(jobs []trackJob) string {
+	if len(jobs) == 0 {
+		return ""
+	}
+
+	dir := path.Dir(jobs[0].output)
+	for _, job := range jobs[1:] {
+		for dir != "." && dir != "/" && !strings.HasPrefix(path.Dir(job.output)+"/", dir+"/") {
+			dir = path.Dir(dir)
+		}
+	}
+
+	return dir
 }
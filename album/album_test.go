@@ -0,0 +1,134 @@
+package album
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nanoteck137/slurpuff/audio"
+	"github.com/nanoteck137/slurpuff/cue"
+	"github.com/nanoteck137/slurpuff/id3"
+	"github.com/nanoteck137/slurpuff/types"
+)
+
+func TestTrimFilter(t *testing.T) {
+	// NOTE(patrik): Track 2 starting at 03:12:15 and track 3 at 07:01:00
+	// inside a 44.1 kHz file
+	start := cue.FramesToSamples(192*75+15, 44100)
+	end := cue.FramesToSamples(421*75, 44100)
+
+	tests := []struct {
+		file   types.TrackFile
+		filter string
+	}{
+		{
+			file:   types.TrackFile{Start: start, End: end},
+			filter: "atrim=start_sample=8476020:end_sample=18566100,asetpts=PTS-STARTPTS",
+		},
+		{
+			file:   types.TrackFile{Start: end},
+			filter: "atrim=start_sample=18566100,asetpts=PTS-STARTPTS",
+		},
+		{
+			file:   types.TrackFile{End: start},
+			filter: "atrim=start_sample=0:end_sample=8476020,asetpts=PTS-STARTPTS",
+		},
+	}
+
+	for _, test := range tests {
+		filter := audio.TrimFilter(test.file.Start, test.file.End)
+		if filter != test.filter {
+			t.Errorf("TrimFilter(%+v) = %q, expected %q", test.file, filter, test.filter)
+		}
+	}
+}
+
+func TestReadConfigOldFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	data := `album = "Album"
+artist = "Artist"
+coverart = "cover.jpg"
+
+[[tracks]]
+filename = "01.flac"
+num = 1
+name = "One"
+date = "2020-05-01"
+
+[[tracks]]
+filename = "02.mp3"
+num = 2
+name = "Two"
+artist = "Other"
+date = "2021"
+`
+
+	err := os.WriteFile(path.Join(dir, "album.toml"), []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ReadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := types.AlbumMetadata{
+		Album:    "Album",
+		Artist:   "Artist",
+		CoverArt: "cover.jpg",
+		Tracks: []types.TrackMetadata{
+			{
+				Num:  1,
+				Name: "One",
+				Year: 2020,
+				File: types.TrackFile{Lossless: "01.flac"},
+			},
+			{
+				Num:    2,
+				Name:   "Two",
+				Artist: "Other",
+				Year:   2021,
+				File:   types.TrackFile{Lossy: "02.mp3"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("got %+v, expected %+v", config, expected)
+	}
+}
+
+func TestTrackTagsAlbumArtistSort(t *testing.T) {
+	config := types.AlbumMetadata{
+		Album:      "Album",
+		Artist:     "The Beatles",
+		ArtistSort: "Beatles, The",
+		Tracks: []types.TrackMetadata{
+			{Num: 1, Name: "One"},
+		},
+	}
+
+	tags := buildTrackTags(config, config.Tracks[0], config.Artist)
+
+	// NOTE(patrik): ffmpeg writes unknown ID3 keys as TXXX frames, the
+	// album artist sort is written as TSO2 after the export
+	for _, arg := range tags.args(containerID3) {
+		if strings.HasPrefix(arg, "TSO2=") {
+			t.Errorf("album artist sort passed to ffmpeg: %q", arg)
+		}
+	}
+
+	frames := tags.id3Frames(containerID3)
+	expected := []id3.Frame{{ID: "TSO2", Value: "Beatles, The"}}
+	if !reflect.DeepEqual(frames, expected) {
+		t.Errorf("got %v, expected %v", frames, expected)
+	}
+
+	if frames := tags.id3Frames(containerVorbis); frames != nil {
+		t.Errorf("vorbis: got %v, expected no frames", frames)
+	}
+}
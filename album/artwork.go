@@ -0,0 +1,173 @@
+package album
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/cover"
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+type picture struct {
+	kind string
+	path string
+}
+
+// NOTE(patrik): Picture types used by ffmpeg for the ID3 APIC frames and
+// the FLAC picture blocks, set with the comment of the stream
+var pictureTypeNames = map[string]string{
+	types.ArtworkFront:  "Cover (front)",
+	types.ArtworkBack:   "Cover (back)",
+	types.ArtworkDisc:   "Media (e.g. label side of CD)",
+	types.ArtworkArtist: "Lead artist/lead performer/soloist",
+}
+
+// embedTypes returns the artwork types ffmpeg can embed in the output
+// format, opus files gets the front cover with opusimage instead
+func embedTypes(ext string) []string {
+	switch ext {
+	case ".mp3", ".flac":
+		return []string{types.ArtworkFront, types.ArtworkBack, types.ArtworkDisc, types.ArtworkArtist}
+	case ".m4a":
+		// NOTE(patrik): The covr atom has no picture types
+		return []string{types.ArtworkFront}
+	}
+
+	return nil
+}
+
+func embedsArtwork(jobs []trackJob) bool {
+	for _, job := range jobs {
+		if len(embedTypes(job.ext)) > 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ffmpegArgs returns the full ffmpeg arguments for the job writing to
+// output with the pictures the output format supports embedded
+func (job trackJob) ffmpegArgs(pictures []picture, output string) []string {
+	var embedded []picture
+	for _, kind := range embedTypes(job.ext) {
+		for _, pic := range pictures {
+			if pic.kind == kind {
+				embedded = append(embedded, pic)
+			}
+		}
+	}
+
+	// NOTE(patrik): The progress is written to stdout and read by
+	// progress.Parse
+	args := []string{"-y", "-nostats", "-progress", "pipe:1", "-i", job.input}
+	for _, pic := range embedded {
+		args = append(args, "-i", pic.path)
+	}
+
+	args = append(args, "-map", "0:a")
+	for i := range embedded {
+		args = append(args, "-map", strconv.Itoa(i+1))
+	}
+
+	args = append(args, job.args...)
+
+	if len(embedded) > 0 {
+		args = append(args, "-c:v", "copy", "-disposition:v", "attached_pic")
+		for i, pic := range embedded {
+			args = append(args, fmt.Sprintf("-metadata:s:v:%d", i), "comment="+pictureTypeNames[pic.kind])
+		}
+	}
+
+	return append(args, output)
+}
+
+// embedPictures creates the embedded copies of the artwork, the caller is
+// responsible for removing the files
+func embedPictures(config types.AlbumMetadata, src string, opts cover.Options) ([]picture, error) {
+	var res []picture
+
+	for _, artwork := range config.Artwork {
+		if _, embeddable := pictureTypeNames[artwork.Type]; !embeddable {
+			continue
+		}
+
+		// NOTE(patrik): CoverArt is the front cover if set
+		if artwork.Type == types.ArtworkFront && config.CoverArt != "" {
+			continue
+		}
+
+		if !utils.IsValidCoverExt(path.Ext(artwork.Path)) {
+			continue
+		}
+
+		p, err := cover.EmbedCopy(path.Join(src, artwork.Path), opts)
+		if err != nil {
+			for _, pic := range res {
+				os.Remove(pic.path)
+			}
+
+			return nil, err
+		}
+
+		res = append(res, picture{
+			kind: artwork.Type,
+			path: p,
+		})
+	}
+
+	return res, nil
+}
+
+// copyArtwork copies the artwork with the types to the artwork folder
+// inside the album folder, all types is copied if kinds is empty
+func copyArtwork(config types.AlbumMetadata, src, albumDir string, kinds []string, opts cover.Options) error {
+	counts := make(map[string]int)
+
+	for _, artwork := range config.Artwork {
+		if len(kinds) > 0 && !contains(kinds, artwork.Type) {
+			continue
+		}
+
+		counts[artwork.Type]++
+
+		name := artwork.Type
+		if counts[artwork.Type] > 1 {
+			name += "-" + strconv.Itoa(counts[artwork.Type])
+		}
+
+		dir := path.Join(albumDir, "artwork")
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+
+		srcPath := path.Join(src, artwork.Path)
+
+		if utils.IsValidCoverExt(path.Ext(artwork.Path)) {
+			_, err = cover.WriteCopy(srcPath, dir, name, opts)
+		} else {
+			_, err = utils.Copy(srcPath, path.Join(dir, name+strings.ToLower(path.Ext(artwork.Path))))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
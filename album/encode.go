@@ -0,0 +1,318 @@
+package album
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+const (
+	ResamplerSoxr = "soxr"
+	ResamplerSwr  = "swr"
+
+	DefaultResampler = ResamplerSoxr
+)
+
+func IsValidResampler(resampler string) bool {
+	switch resampler {
+	case ResamplerSoxr, ResamplerSwr:
+		return true
+	}
+
+	return false
+}
+
+// encodeProfile is the target of the modes that transcodes the tracks
+type encodeProfile struct {
+	codec string
+	ext   string
+
+	// Lossless profiles never transcodes lossy sources, they are copied
+	// as is
+	lossless bool
+
+	// Nominal bit rate of the encoded tracks in bits per second, not used
+	// for lossless profiles
+	bitRate int
+
+	// Forced output sample rate, 0 keeps the source sample rate (limited
+	// by maxSampleRate)
+	sampleRate int
+
+	// Maximum sample rate and bit depth of the output, 0 for no limit
+	maxSampleRate int
+	maxBitDepth   int
+
+	// args returns the encoder arguments for the bit rate
+	args func(bitRate int) []string
+}
+
+var encodeProfiles = map[string]encodeProfile{
+	ModeOpus: {
+		codec:   "opus",
+		ext:     ".opus",
+		bitRate: 128000,
+
+		// NOTE(patrik): Opus always runs at 48 kHz internally, resample
+		// it ourself instead of letting ffmpeg insert the default
+		// resampler
+		sampleRate: 48000,
+
+		args: func(bitRate int) []string {
+			return []string{"-vbr", "on", "-b:a", kbps(bitRate)}
+		},
+	},
+	ModeMp3: {
+		codec:         "mp3",
+		ext:           ".mp3",
+		bitRate:       245000,
+		maxSampleRate: 48000,
+		args: func(bitRate int) []string {
+			// NOTE(patrik): The Xing/LAME header stores the encoder delay
+			// and padding needed for gapless playback
+			args := []string{"-write_xing", "1"}
+
+			// NOTE(patrik): V0 for the full bit rate, lower bit rates is
+			// only used when the source is a low bit rate lossy file
+			if bitRate >= 245000 {
+				return append(args, "-q:a", "0")
+			}
+
+			return append(args, "-b:a", kbps(bitRate))
+		},
+	},
+	ModeAac: {
+		codec:         "aac",
+		ext:           ".m4a",
+		bitRate:       256000,
+		maxSampleRate: 48000,
+
+		// NOTE(patrik): The encoder delay and padding is stored in the
+		// edit list by ffmpeg, iTunSMPB is added after the encode
+		args: func(bitRate int) []string {
+			return []string{"-c:a", "aac", "-b:a", kbps(bitRate)}
+		},
+	},
+	ModeLosslessPortable: {
+		codec:         "flac",
+		ext:           ".flac",
+		lossless:      true,
+		maxSampleRate: 44100,
+		maxBitDepth:   16,
+		args: func(bitRate int) []string {
+			return []string{"-compression_level", "8"}
+		},
+	},
+}
+
+// NOTE(patrik): Dwebble and map keeps the sources as is and has no limits
+// of their own, lossless sources over the limits set with Options is
+// converted to flac with this profile
+var limitProfile = encodeProfile{
+	codec:    "flac",
+	ext:      ".flac",
+	lossless: true,
+	args: func(bitRate int) []string {
+		return []string{"-compression_level", "8"}
+	},
+}
+
+// IsValidBitDepth reports if the bit depth can be used as a limit, 0 is no
+// limit
+func IsValidBitDepth(bitDepth int) bool {
+	switch bitDepth {
+	case 0, 16, 24:
+		return true
+	}
+
+	return false
+}
+
+// lowerLimit returns the lowest of the limits, 0 is no limit
+func lowerLimit(a, b int) int {
+	if a == 0 {
+		return b
+	}
+
+	if b == 0 {
+		return a
+	}
+
+	return min(a, b)
+}
+
+// limit returns the profile with the sample rate and bit depth limited
+// further, the limits of the profile can only be lowered since they are
+// the limits of the codec or the target devices
+func (p encodeProfile) limit(maxSampleRate, maxBitDepth int) encodeProfile {
+	p.maxSampleRate = lowerLimit(p.maxSampleRate, maxSampleRate)
+	p.maxBitDepth = lowerLimit(p.maxBitDepth, maxBitDepth)
+	return p
+}
+
+// withinLimits reports if the sample rate and bit depth of the source is
+// inside the limits of the profile
+func (p encodeProfile) withinLimits(info utils.Info) bool {
+	if p.maxSampleRate != 0 && info.SampleRate > p.maxSampleRate {
+		return false
+	}
+
+	if p.maxBitDepth != 0 && info.BitsPerSample > p.maxBitDepth {
+		return false
+	}
+
+	return true
+}
+
+func kbps(bitRate int) string {
+	return strconv.Itoa(bitRate/1000) + "k"
+}
+
+// NOTE(patrik): Integer ratios between the rates of the same family
+// (44.1 kHz and 48 kHz) gives the cleanest conversion
+var sampleRateFamilies = []int{44100, 48000}
+
+// outputSampleRate returns the sample rate the source should be encoded
+// with
+func (p encodeProfile) outputSampleRate(sampleRate int) int {
+	if p.sampleRate != 0 {
+		return p.sampleRate
+	}
+
+	if p.maxSampleRate == 0 || sampleRate <= p.maxSampleRate {
+		return sampleRate
+	}
+
+	for _, base := range sampleRateFamilies {
+		if sampleRate%base == 0 && base <= p.maxSampleRate {
+			rate := base
+			for rate*2 <= p.maxSampleRate {
+				rate *= 2
+			}
+
+			return rate
+		}
+	}
+
+	return p.maxSampleRate
+}
+
+// meetsProfile reports if the source can be used without any conversion
+func (p encodeProfile) meetsProfile(info utils.Info) bool {
+	if info.Codec != p.codec {
+		return false
+	}
+
+	if p.sampleRate != 0 && info.SampleRate != p.sampleRate {
+		return false
+	}
+
+	return p.withinLimits(info)
+}
+
+// resample returns the filter and arguments converting the source to the
+// sample rate and bit depth of the profile, empty if no conversion is
+// needed
+func (p encodeProfile) resample(info utils.Info, resampler string) (string, []string) {
+	sampleRate := info.SampleRate
+	if sampleRate != 0 {
+		sampleRate = p.outputSampleRate(info.SampleRate)
+	}
+
+	resample := sampleRate != 0 && sampleRate != info.SampleRate
+	dither := p.maxBitDepth != 0 && info.BitsPerSample > p.maxBitDepth
+
+	if !resample && !dither {
+		return "", nil
+	}
+
+	var filter []string
+
+	switch resampler {
+	case ResamplerSoxr:
+		filter = append(filter, "resampler=soxr", "precision=28")
+	case ResamplerSwr:
+		filter = append(filter, "resampler=swr", "filter_size=64", "phase_shift=10", "cutoff=0.97")
+	}
+
+	if resample {
+		filter = append(filter, "osr="+strconv.Itoa(sampleRate))
+	}
+
+	var args []string
+
+	// NOTE(patrik): Only the bit depth reduction needs dither, the lossy
+	// encoders works with floating point samples
+	if dither {
+		format := "s16"
+		if p.maxBitDepth > 16 {
+			format = "s32"
+		}
+
+		filter = append(filter, "osf="+format, "dither_method=triangular_hp")
+		args = append(args, "-sample_fmt", format)
+	}
+
+	if resample {
+		args = append(args, "-ar", strconv.Itoa(sampleRate))
+	}
+
+	return "aresample=" + strings.Join(filter, ":"), args
+}
+
+// encodePlan is how a source is exported, copies keeps the extension of
+// the source (ext is empty)
+type encodePlan struct {
+	copy    bool
+	ext     string
+	filters []string
+	args    []string
+}
+
+func (p encodeProfile) encode(info utils.Info, bitRate int, resampler string) encodePlan {
+	plan := encodePlan{
+		ext: p.ext,
+	}
+
+	filter, args := p.resample(info, resampler)
+	if filter != "" {
+		plan.filters = append(plan.filters, filter)
+	}
+
+	plan.args = append(args, p.args(bitRate)...)
+
+	return plan
+}
+
+// plan decides how a source is exported with the profile, sources that
+// already meets the profile is copied as is. Transcoding lossy sources to
+// another lossy codec is refused unless allowLossy is set, the bit rate is
+// then capped to the source bit rate so we never "upgrade" a file.
+func (p encodeProfile) plan(info utils.Info, allowLossy bool, resampler string) (encodePlan, error) {
+	if info.IsLossless() {
+		if p.meetsProfile(info) {
+			return encodePlan{copy: true}, nil
+		}
+
+		return p.encode(info, p.bitRate, resampler), nil
+	}
+
+	// NOTE(patrik): A higher bit rate source is still better than what we
+	// would get by transcoding it again
+	if p.lossless || info.Codec == p.codec {
+		return encodePlan{copy: true}, nil
+	}
+
+	if !allowLossy {
+		return encodePlan{}, fmt.Errorf("refusing to transcode lossy %s (%s) to %s, use --allow-lossy-transcode to force", info.Codec, kbps(info.BitRate), p.codec)
+	}
+
+	bitRate := p.bitRate
+	if info.BitRate > 0 && info.BitRate < bitRate {
+		bitRate = info.BitRate
+	}
+
+	return p.encode(info, bitRate, resampler), nil
+}
@@ -0,0 +1,52 @@
+package album
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+func TestEncodeProfileLimit(t *testing.T) {
+	hiRes := utils.Info{Codec: "flac", SampleRate: 96000, BitsPerSample: 24}
+
+	// NOTE(patrik): 24/96 passes through dwebble unless it's limited
+	encoder := limitProfile.limit(48000, 16)
+	if encoder.withinLimits(hiRes) {
+		t.Fatal("expected 24/96 to be over the limits")
+	}
+
+	plan := encoder.encode(hiRes, 0, ResamplerSoxr)
+	if plan.ext != ".flac" {
+		t.Errorf("got ext %q, expected .flac", plan.ext)
+	}
+
+	expectedFilters := []string{"aresample=resampler=soxr:precision=28:osr=48000:osf=s16:dither_method=triangular_hp"}
+	if !reflect.DeepEqual(plan.filters, expectedFilters) {
+		t.Errorf("got filters %v, expected %v", plan.filters, expectedFilters)
+	}
+
+	expectedArgs := []string{"-sample_fmt", "s16", "-ar", "48000", "-compression_level", "8"}
+	if !reflect.DeepEqual(plan.args, expectedArgs) {
+		t.Errorf("got args %v, expected %v", plan.args, expectedArgs)
+	}
+
+	if !encoder.withinLimits(utils.Info{Codec: "flac", SampleRate: 44100, BitsPerSample: 16}) {
+		t.Error("expected 16/44.1 to be inside the limits")
+	}
+
+	if !limitProfile.limit(0, 0).withinLimits(hiRes) {
+		t.Error("expected no limits when none is set")
+	}
+
+	// NOTE(patrik): The limits of the codec can't be raised
+	mp3 := encodeProfiles[ModeMp3].limit(96000, 0)
+	if mp3.maxSampleRate != 48000 {
+		t.Errorf("got mp3 max sample rate %d, expected 48000", mp3.maxSampleRate)
+	}
+
+	portable := encodeProfiles[ModeLosslessPortable].limit(0, 24)
+	if portable.maxBitDepth != 16 {
+		t.Errorf("got lossless-portable max bit depth %d, expected 16", portable.maxBitDepth)
+	}
+}
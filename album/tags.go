@@ -0,0 +1,293 @@
+package album
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/id3"
+	"github.com/nanoteck137/slurpuff/mp4"
+	"github.com/nanoteck137/slurpuff/types"
+)
+
+const (
+	containerVorbis = "vorbis"
+	containerID3    = "id3"
+	containerMP4    = "mp4"
+)
+
+func containerForExt(ext string) string {
+	switch ext {
+	case ".mp3":
+		return containerID3
+	case ".m4a", ".mp4":
+		return containerMP4
+	}
+
+	return containerVorbis
+}
+
+const (
+	TagTitle           = "title"
+	TagTitleSort       = "titlesort"
+	TagArtist          = "artist"
+	TagArtistSort      = "artistsort"
+	TagAlbum           = "album"
+	TagAlbumSort       = "albumsort"
+	TagAlbumArtist     = "albumartist"
+	TagAlbumArtistSort = "albumartistsort"
+	TagTrack           = "track"
+	TagDisc            = "disc"
+	TagDate            = "date"
+	TagGenre           = "genre"
+	TagReleaseType     = "releasetype"
+	TagCompilation     = "compilation"
+	TagTags            = "tags"
+	TagFeaturing       = "featuring"
+
+	TagMusicBrainzReleaseID      = "musicbrainz_releaseid"
+	TagMusicBrainzReleaseGroupID = "musicbrainz_releasegroupid"
+	TagMusicBrainzRecordingID    = "musicbrainz_recordingid"
+	TagMusicBrainzArtistID       = "musicbrainz_artistid"
+	TagMusicBrainzAlbumArtistID  = "musicbrainz_albumartistid"
+	TagDiscogsReleaseID          = "discogs_releaseid"
+	TagISRC                      = "isrc"
+	TagBarcode                   = "barcode"
+)
+
+// NOTE(patrik): The names are the keys ffmpeg understands for the
+// container, tags missing from a container uses the generic name
+var tagNames = map[string]map[string]string{
+	containerVorbis: {
+		TagTitleSort:       "TITLESORT",
+		TagArtistSort:      "ARTISTSORT",
+		TagAlbumSort:       "ALBUMSORT",
+		TagAlbumArtist:     "album_artist",
+		TagAlbumArtistSort: "ALBUMARTISTSORT",
+		TagReleaseType:     "RELEASETYPE",
+
+		TagMusicBrainzReleaseID:      "MUSICBRAINZ_ALBUMID",
+		TagMusicBrainzReleaseGroupID: "MUSICBRAINZ_RELEASEGROUPID",
+		TagMusicBrainzRecordingID:    "MUSICBRAINZ_TRACKID",
+		TagMusicBrainzArtistID:       "MUSICBRAINZ_ARTISTID",
+		TagMusicBrainzAlbumArtistID:  "MUSICBRAINZ_ALBUMARTISTID",
+		TagDiscogsReleaseID:          "DISCOGS_RELEASE_ID",
+		TagISRC:                      "ISRC",
+		TagBarcode:                   "BARCODE",
+	},
+	// NOTE(patrik): Names with spaces is written as TXXX frames, ffmpeg
+	// can't write the UFID frame so the recording id is also a TXXX frame
+	containerID3: {
+		TagTitleSort:       "title-sort",
+		TagArtistSort:      "artist-sort",
+		TagAlbumSort:       "album-sort",
+		TagAlbumArtist:     "album_artist",
+		TagAlbumArtistSort: "TSO2",
+		TagReleaseType:     "RELEASETYPE",
+
+		TagMusicBrainzReleaseID:      "MusicBrainz Album Id",
+		TagMusicBrainzReleaseGroupID: "MusicBrainz Release Group Id",
+		TagMusicBrainzRecordingID:    "MusicBrainz Track Id",
+		TagMusicBrainzArtistID:       "MusicBrainz Artist Id",
+		TagMusicBrainzAlbumArtistID:  "MusicBrainz Album Artist Id",
+		TagDiscogsReleaseID:          "DISCOGS_RELEASE_ID",
+		TagISRC:                      "TSRC",
+		TagBarcode:                   "BARCODE",
+	},
+	// NOTE(patrik): The names without an atom of their own is the
+	// freeform names used by Picard
+	containerMP4: {
+		TagTitleSort:       "sort_name",
+		TagArtistSort:      "sort_artist",
+		TagAlbumSort:       "sort_album",
+		TagAlbumArtist:     "album_artist",
+		TagAlbumArtistSort: "sort_album_artist",
+		TagReleaseType:     "MusicBrainz Album Type",
+
+		TagMusicBrainzReleaseID:      "MusicBrainz Album Id",
+		TagMusicBrainzReleaseGroupID: "MusicBrainz Release Group Id",
+		TagMusicBrainzRecordingID:    "MusicBrainz Track Id",
+		TagMusicBrainzArtistID:       "MusicBrainz Artist Id",
+		TagMusicBrainzAlbumArtistID:  "MusicBrainz Album Artist Id",
+		TagDiscogsReleaseID:          "DISCOGS_RELEASE_ID",
+		TagISRC:                      "ISRC",
+		TagBarcode:                   "BARCODE",
+	},
+}
+
+// NOTE(patrik): The ffmpeg mov muxer drops the keys it doesn't have an
+// atom for, the other tags is written as freeform atoms after the export
+// with mp4.WriteTags
+var mp4AtomTags = map[string]bool{
+	TagTitle:           true,
+	TagTitleSort:       true,
+	TagArtist:          true,
+	TagArtistSort:      true,
+	TagAlbum:           true,
+	TagAlbumSort:       true,
+	TagAlbumArtist:     true,
+	TagAlbumArtistSort: true,
+	TagTrack:           true,
+	TagDisc:            true,
+	TagDate:            true,
+	TagGenre:           true,
+	TagCompilation:     true,
+}
+
+// NOTE(patrik): The ID3 frames ffmpeg has no mapping for, ffmpeg would
+// write them as TXXX frames so they are added after the export with
+// id3.WriteTextFrames
+var id3FrameTags = map[string]bool{
+	TagAlbumArtistSort: true,
+}
+
+func tagName(container, tag string) string {
+	if name, exists := tagNames[container][tag]; exists {
+		return name
+	}
+
+	return tag
+}
+
+type trackTag struct {
+	name  string
+	value string
+}
+
+type trackTags []trackTag
+
+func (t *trackTags) set(name, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	*t = append(*t, trackTag{name: name, value: value})
+}
+
+// setSort only sets the sort tag if it differs from the display value
+func (t *trackTags) setSort(name, sort, value string) {
+	if sort != "" && sort != value {
+		t.set(name, sort)
+	}
+}
+
+func (t trackTags) args(container string) []string {
+	var args []string
+	for _, tag := range t {
+		if container == containerMP4 && !mp4AtomTags[tag.name] {
+			continue
+		}
+
+		if container == containerID3 && id3FrameTags[tag.name] {
+			continue
+		}
+
+		args = append(args, "-metadata", fmt.Sprintf("%s=%s", tagName(container, tag.name), tag.value))
+	}
+
+	return args
+}
+
+// freeform returns the tags ffmpeg can't write to the container, only mp4
+// has any
+func (t trackTags) freeform(container string) []mp4.Tag {
+	if container != containerMP4 {
+		return nil
+	}
+
+	var res []mp4.Tag
+	for _, tag := range t {
+		if !mp4AtomTags[tag.name] {
+			res = append(res, mp4.Tag{
+				Name:  tagName(container, tag.name),
+				Value: tag.value,
+			})
+		}
+	}
+
+	return res
+}
+
+// id3Frames returns the frames ffmpeg can't write to the ID3 tag
+func (t trackTags) id3Frames(container string) []id3.Frame {
+	if container != containerID3 {
+		return nil
+	}
+
+	var res []id3.Frame
+	for _, tag := range t {
+		if id3FrameTags[tag.name] {
+			res = append(res, id3.Frame{
+				ID:    tagName(container, tag.name),
+				Value: tag.value,
+			})
+		}
+	}
+
+	return res
+}
+
+func buildTrackTags(config types.AlbumMetadata, track types.TrackMetadata, artistName string) trackTags {
+	var tags trackTags
+
+	artist := track.Artist
+	artistSort := track.ArtistSort
+	if artist == "" {
+		artist = config.Artist
+		artistSort = config.ArtistSort
+	}
+
+	tags.set(TagTitle, track.Name)
+	tags.setSort(TagTitleSort, track.NameSort, track.Name)
+	tags.set(TagArtist, artist)
+	tags.setSort(TagArtistSort, artistSort, artist)
+	tags.set(TagAlbumArtist, artistName)
+	if artistName == config.Artist {
+		tags.setSort(TagAlbumArtistSort, config.ArtistSort, artistName)
+	}
+	tags.set(TagAlbum, config.Album)
+	tags.setSort(TagAlbumSort, config.AlbumSort, config.Album)
+	tags.set(TagTrack, strconv.Itoa(track.Num))
+
+	if track.Disc != 0 {
+		tags.set(TagDisc, strconv.Itoa(track.Disc))
+	}
+
+	tags.set(TagReleaseType, config.Type)
+
+	if config.IsCompilation() {
+		tags.set(TagCompilation, "1")
+	}
+
+	tags.set(TagTags, strings.Join(track.Tags, ","))
+
+	if track.Year != 0 {
+		tags.set(TagDate, strconv.Itoa(track.Year))
+	}
+
+	tags.set(TagFeaturing, strings.Join(track.Featuring, ","))
+	tags.set(TagGenre, strings.Join(track.Genres, ","))
+
+	tags.set(TagMusicBrainzReleaseID, config.MusicBrainzReleaseID)
+	tags.set(TagMusicBrainzReleaseGroupID, config.MusicBrainzReleaseGroupID)
+	tags.set(TagMusicBrainzRecordingID, track.MusicBrainzRecordingID)
+
+	artistID := track.MusicBrainzArtistID
+	if track.Artist == "" {
+		artistID = config.MusicBrainzArtistID
+	}
+	tags.set(TagMusicBrainzArtistID, artistID)
+
+	// NOTE(patrik): Compilations uses the various artists name so the
+	// album artist id don't apply
+	if artistName == config.Artist {
+		tags.set(TagMusicBrainzAlbumArtistID, config.MusicBrainzArtistID)
+	}
+
+	tags.set(TagDiscogsReleaseID, config.DiscogsReleaseID)
+	tags.set(TagISRC, track.ISRC)
+	tags.set(TagBarcode, config.Barcode)
+
+	return tags
+}
@@ -0,0 +1,269 @@
+package album
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Template syntax:
+//
+//	{field}      - replaced with the value of field
+//	{field:02}   - zero padded to the given width (numbers only)
+//	[...]        - optional group, removed if any field inside is empty
+//	/            - path separator, each segment is made safe on its own
+//
+// Example: "{artist}/[{year} - ]{album}/{num:02} - {title}{ext}"
+const DefaultTemplate = "{artist}/{album}/{num:02} - {title}{ext}"
+
+var templateFields = []string{
+	"artist",
+	"artist_sort",
+	"artist_alt",
+	"track_artist",
+	"track_artist_sort",
+	"track_artist_alt",
+	"album",
+	"album_sort",
+	"album_alt",
+	"year",
+	"disc",
+	"num",
+	"title",
+	"title_sort",
+	"title_alt",
+	"ext",
+	"genre",
+	"type",
+}
+
+func isValidTemplateField(name string) bool {
+	for _, field := range templateFields {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+type templateNode struct {
+	literal string
+
+	field string
+	width int
+
+	optional []templateNode
+}
+
+type Template struct {
+	source   string
+	segments [][]templateNode
+}
+
+func (t *Template) String() string {
+	return t.source
+}
+
+func ParseTemplate(s string) (*Template, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("template: empty template")
+	}
+
+	var segments [][]templateNode
+	for _, part := range strings.Split(s, "/") {
+		nodes, rest, err := parseTemplateNodes(part)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", s, err)
+		}
+
+		if rest != "" {
+			return nil, fmt.Errorf("template %q: unexpected ']'", s)
+		}
+
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("template %q: empty path segment", s)
+		}
+
+		segments = append(segments, nodes)
+	}
+
+	// NOTE(patrik): The output format is picked from the extension of the
+	// file name, ffmpeg fails without it
+	file := segments[len(segments)-1]
+	if last := file[len(file)-1]; last.field != "ext" {
+		return nil, fmt.Errorf("template %q: the file name must end with {ext}", s)
+	}
+
+	return &Template{
+		source:   s,
+		segments: segments,
+	}, nil
+}
+
+// parseTemplateNodes parses nodes until the end of the string or until a
+// closing ']', the remaining string is returned
+func parseTemplateNodes(s string) ([]templateNode, string, error) {
+	var nodes []templateNode
+	literal := strings.Builder{}
+
+	flush := func() {
+		if literal.Len() > 0 {
+			nodes = append(nodes, templateNode{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for len(s) > 0 {
+		c := s[0]
+
+		switch c {
+		case '{':
+			end := strings.IndexByte(s, '}')
+			if end == -1 {
+				return nil, "", fmt.Errorf("missing '}'")
+			}
+
+			flush()
+
+			node, err := parseTemplateField(s[1:end])
+			if err != nil {
+				return nil, "", err
+			}
+
+			nodes = append(nodes, node)
+			s = s[end+1:]
+		case '[':
+			flush()
+
+			group, rest, err := parseTemplateNodes(s[1:])
+			if err != nil {
+				return nil, "", err
+			}
+
+			if len(rest) == 0 || rest[0] != ']' {
+				return nil, "", fmt.Errorf("missing ']'")
+			}
+
+			nodes = append(nodes, templateNode{optional: group})
+			s = rest[1:]
+		case ']':
+			// NOTE(patrik): The caller reports the error if we are not
+			// inside a group
+			flush()
+			return nodes, s, nil
+		case '}':
+			return nil, "", fmt.Errorf("unexpected '}'")
+		default:
+			literal.WriteByte(c)
+			s = s[1:]
+		}
+	}
+
+	flush()
+
+	return nodes, "", nil
+}
+
+func parseTemplateField(s string) (templateNode, error) {
+	name, spec, hasSpec := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+
+	if !isValidTemplateField(name) {
+		return templateNode{}, fmt.Errorf("unknown field '%s'", name)
+	}
+
+	width := 0
+	if hasSpec {
+		w, err := strconv.Atoi(spec)
+		if err != nil || w < 0 {
+			return templateNode{}, fmt.Errorf("invalid width for field '%s': %s", name, spec)
+		}
+
+		width = w
+	}
+
+	return templateNode{
+		field: name,
+		width: width,
+	}, nil
+}
+
+// renderNodes returns false if one of the fields was empty
+func renderNodes(b *strings.Builder, nodes []templateNode, values map[string]string) bool {
+	ok := true
+
+	for _, node := range nodes {
+		switch {
+		case node.optional != nil:
+			group := strings.Builder{}
+			if renderNodes(&group, node.optional, values) {
+				b.WriteString(group.String())
+			}
+		case node.field != "":
+			value := values[node.field]
+			if value == "" {
+				ok = false
+				continue
+			}
+
+			if node.width > 0 {
+				if _, err := strconv.Atoi(value); err == nil {
+					for i := len(value); i < node.width; i++ {
+						b.WriteByte('0')
+					}
+				}
+			}
+
+			b.WriteString(value)
+		default:
+			b.WriteString(node.literal)
+		}
+	}
+
+	return ok
+}
+
+func collectFields(nodes []templateNode, fields map[string]bool) {
+	for _, node := range nodes {
+		if node.field != "" {
+			fields[node.field] = true
+		}
+
+		collectFields(node.optional, fields)
+	}
+}
+
+// SegmentUses reports if the path segment at index i references the field
+// or the sort and alternate forms of it (album_sort, album_alt)
+func (t *Template) SegmentUses(i int, field string) bool {
+	fields := make(map[string]bool)
+	collectFields(t.segments[i], fields)
+
+	for name := range fields {
+		if name == field || strings.HasPrefix(name, field+"_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Execute renders the template and returns the unsanitized path segments
+func (t *Template) Execute(values map[string]string) ([]string, error) {
+	res := make([]string, 0, len(t.segments))
+
+	for _, segment := range t.segments {
+		b := strings.Builder{}
+		renderNodes(&b, segment, values)
+
+		s := strings.TrimSpace(b.String())
+		if s == "" {
+			return nil, fmt.Errorf("template %q: rendered an empty path segment", t.source)
+		}
+
+		res = append(res, s)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,94 @@
+package album
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		err      string
+	}{
+		{DefaultTemplate, ""},
+		{"{artist}/[{year} - ]{album}/{num:02} - {title}{ext}", ""},
+		{"{num:02} {title}{ext}", ""},
+		{"", "empty template"},
+		{"{artist}//{title}{ext}", "empty path segment"},
+		{"{artist}/{title}", "must end with {ext}"},
+		{"{artist}/{title}.flac", "must end with {ext}"},
+		{"{artist}/{title}[{ext}]", "must end with {ext}"},
+		{"{ext}/{title}", "must end with {ext}"},
+		{"{artist}/{unknown}{ext}", "unknown field 'unknown'"},
+		{"{artist/{title}{ext}", "missing '}'"},
+		{"{artist}/[{year} - {title}{ext}", "missing ']'"},
+		{"{num:x}{ext}", "invalid width"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseTemplate(test.template)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%q: %v", test.template, err)
+			}
+
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error %q, got %v", test.template, test.err, err)
+		}
+	}
+}
+
+func TestTemplateSegmentUses(t *testing.T) {
+	template, err := ParseTemplate("{artist_sort}/[{year} - ]{album_alt}/{track_artist} - {title}{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		segment int
+		field   string
+		uses    bool
+	}{
+		{0, "artist", true},
+		{0, "album", false},
+		{1, "album", true},
+		{1, "year", true},
+		{1, "artist", false},
+		{2, "artist", false},
+		{2, "track_artist", true},
+		{2, "title", true},
+	}
+
+	for _, test := range tests {
+		if uses := template.SegmentUses(test.segment, test.field); uses != test.uses {
+			t.Errorf("SegmentUses(%d, %q) = %v, expected %v", test.segment, test.field, uses, test.uses)
+		}
+	}
+}
+
+func TestTemplateExecute(t *testing.T) {
+	template, err := ParseTemplate("{artist}/[{year} - ]{album}/[{disc}-]{num:02} - {title}{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := template.Execute(map[string]string{
+		"artist": "Artist",
+		"album":  "Album",
+		"num":    "3",
+		"title":  "Title",
+		"ext":    ".opus",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Artist", "Album", "03 - Title.opus"}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("got %q, expected %q", segments, expected)
+	}
+}
@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+// TrimFilter returns the ffmpeg filter cutting out the part between the
+// start and end sample (end 0 is the end of the file)
+func TrimFilter(start, end int64) string {
+	filter := "atrim=start_sample=" + strconv.FormatInt(start, 10)
+	if end != 0 {
+		filter += ":end_sample=" + strconv.FormatInt(end, 10)
+	}
+
+	return filter + ",asetpts=PTS-STARTPTS"
+}
+
+// DecodeMono decodes the file with ffmpeg to signed 16-bit mono samples at
+// the sample rate, maxDuration limits the decoded length in seconds (0 for
+// the whole file)
+func DecodeMono(p string, sampleRate int, maxDuration int) ([]int16, error) {
+	return DecodeMonoRange(p, 0, 0, sampleRate, maxDuration)
+}
+
+// DecodeMonoRange is DecodeMono for the part of the file between the start
+// and end sample (end 0 is the end of the file), the positions is in the
+// sample rate of the file like the ranges of split tracks
+func DecodeMonoRange(p string, start, end int64, sampleRate int, maxDuration int) ([]int16, error) {
+	args := []string{"-v", "error", "-i", p, "-vn"}
+
+	// NOTE(patrik): atrim runs before the resampling so the positions is
+	// the same as the ones used when exporting the track
+	if start != 0 || end != 0 {
+		args = append(args, "-af", TrimFilter(start, end))
+	}
+
+	if maxDuration > 0 {
+		args = append(args, "-t", strconv.Itoa(maxDuration))
+	}
+
+	args = append(args, "-ac", "1", "-ar", strconv.Itoa(sampleRate), "-f", "s16le", "-")
+
+	cmd := exec.Command("ffmpeg", args...)
+
+	stderr := utils.CaptureStderr(cmd)
+
+	data, err := cmd.Output()
+	err = stderr.Done(err)
+	if err != nil {
+		return nil, fmt.Errorf("decoding '%s': %w", p, err)
+	}
+
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+
+	return samples, nil
+}
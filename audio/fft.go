@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// FFT computes the in-place radix-2 FFT, the length of x needs to be a
+// power of two
+func FFT(x []complex128) {
+	n := len(x)
+	if n <= 1 {
+		return
+	}
+
+	shift := 64 - uint(bits.Len(uint(n-1)))
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < half; k++ {
+				a := x[start+k]
+				b := x[start+k+half] * w
+
+				x[start+k] = a + b
+				x[start+k+half] = a - b
+
+				w *= step
+			}
+		}
+	}
+}
+
+func HammingWindow(size int) []float64 {
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(size-1))
+	}
+
+	return window
+}
+
+// PowerSpectrum returns the squared magnitude of the first len(frame)/2+1
+// bins, the frame is multiplied with the window and scale first
+func PowerSpectrum(frame []float64, window []float64, scale float64, buf []complex128) []float64 {
+	n := len(frame)
+	for i := 0; i < n; i++ {
+		buf[i] = complex(frame[i]*window[i]*scale, 0)
+	}
+
+	FFT(buf[:n])
+
+	res := make([]float64, n/2+1)
+	for i := range res {
+		re, im := real(buf[i]), imag(buf[i])
+		res[i] = re*re + im*im
+	}
+
+	return res
+}
@@ -0,0 +1,153 @@
+package audio
+
+import (
+	"errors"
+	"math"
+)
+
+// NOTE(patrik): Lossy encoders throws away everything above a lowpass
+// frequency (~16 kHz for 128k mp3, ~19-20 kHz for V0/320k) so a "lossless"
+// file made from a lossy one has a hard cutoff well below nyquist. Real
+// recordings rolls off slowly or only at the anti-alias filter.
+
+const (
+	spectrumFrameSize = 4096
+
+	// Length in seconds of the audio that is analyzed
+	SpectrumMaxDuration = 120
+
+	// Frames quieter than this (RMS, 16-bit samples) is skipped, quiet
+	// frames has no high frequency content to speak of
+	minFrameRMS = 100
+
+	// Width of the bands compared on each side of the cutoff
+	cutoffBandWidth = 1000
+
+	// Minimum level difference in dB between the bands for the cutoff
+	// to count as a hard cutoff
+	minCutoffDrop = 25
+
+	// Cutoffs below this frequency is not searched for
+	minCutoffFrequency = 8000
+
+	// Cutoffs above this fraction of nyquist is the normal anti-alias
+	// filter of the recording
+	maxCutoffRatio = 0.95
+)
+
+const (
+	VerdictOk      = "ok"
+	VerdictSuspect = "suspect"
+)
+
+var ErrNotEnoughAudio = errors.New("not enough audio to analyze")
+
+type SpectrumResult struct {
+	SampleRate int
+
+	// Frequency in Hz of the hard cutoff, 0 if none was found
+	Cutoff int
+
+	// Level difference in dB across the cutoff
+	Drop float64
+}
+
+// Suspect reports if the cutoff is low enough that the file most likely
+// was encoded with a lossy encoder at some point
+func (r SpectrumResult) Suspect() bool {
+	nyquist := float64(r.SampleRate) / 2
+	return r.Cutoff > 0 && float64(r.Cutoff) < nyquist*maxCutoffRatio
+}
+
+func (r SpectrumResult) Verdict() string {
+	if r.Suspect() {
+		return VerdictSuspect
+	}
+
+	return VerdictOk
+}
+
+// level returns the average power of the bins in dB
+func level(power []float64) float64 {
+	sum := 0.0
+	for _, p := range power {
+		sum += p
+	}
+
+	return 10 * math.Log10(sum/float64(len(power))+1e-20)
+}
+
+// AnalyzeSpectrum searches the average spectrum of the samples for a hard
+// frequency cutoff
+func AnalyzeSpectrum(samples []int16, sampleRate int) (SpectrumResult, error) {
+	window := HammingWindow(spectrumFrameSize)
+	buf := make([]complex128, spectrumFrameSize)
+	frame := make([]float64, spectrumFrameSize)
+
+	sum := make([]float64, spectrumFrameSize/2+1)
+	frames := 0
+
+	for start := 0; start+spectrumFrameSize <= len(samples); start += spectrumFrameSize {
+		energy := 0.0
+		for i := range frame {
+			frame[i] = float64(samples[start+i])
+			energy += frame[i] * frame[i]
+		}
+
+		if math.Sqrt(energy/spectrumFrameSize) < minFrameRMS {
+			continue
+		}
+
+		power := PowerSpectrum(frame, window, 1.0/32768, buf)
+		for i, p := range power {
+			sum[i] += p
+		}
+
+		frames++
+	}
+
+	if frames == 0 {
+		return SpectrumResult{}, ErrNotEnoughAudio
+	}
+
+	binHz := float64(sampleRate) / spectrumFrameSize
+	width := int(cutoffBandWidth / binHz)
+	first := max(width, int(minCutoffFrequency/binHz))
+
+	res := SpectrumResult{
+		SampleRate: sampleRate,
+	}
+
+	for k := first; k+width < len(sum); k++ {
+		below := level(sum[k-width : k])
+		above := level(sum[k : k+width])
+
+		// NOTE(patrik): The spectrum needs to stay down all the way to
+		// nyquist, otherwise it's just a dip in the spectrum
+		rest := level(sum[k:])
+
+		drop := below - max(above, rest)
+		if drop >= minCutoffDrop && drop > res.Drop {
+			res.Cutoff = int(math.Round(float64(k) * binHz))
+			res.Drop = drop
+		}
+	}
+
+	return res, nil
+}
+
+// AnalyzeFile decodes the file at the sample rate and searches for a hard
+// frequency cutoff, the sample rate should be the sample rate of the file
+// so upsampled files can be detected
+func AnalyzeFile(p string, sampleRate int) (SpectrumResult, error) {
+	if sampleRate <= 0 {
+		sampleRate = 44100
+	}
+
+	samples, err := DecodeMono(p, sampleRate, SpectrumMaxDuration)
+	if err != nil {
+		return SpectrumResult{}, err
+	}
+
+	return AnalyzeSpectrum(samples, sampleRate)
+}
@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+type FlacStreamInfo struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  int64
+	MD5           [16]byte
+}
+
+func (s *FlacStreamInfo) HasMD5() bool {
+	return s.MD5 != [16]byte{}
+}
+
+var ErrNotFlac = errors.New("not a flac file")
+
+// skipID3 skips the ID3v2 tag some taggers put in front of the flac stream
+func skipID3(r io.ReadSeeker) error {
+	header := make([]byte, 10)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return err
+	}
+
+	if string(header[:3]) != "ID3" {
+		_, err := r.Seek(0, io.SeekStart)
+		return err
+	}
+
+	size := int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f)
+	_, err = r.Seek(10+size, io.SeekStart)
+	return err
+}
+
+func ReadFlacStreamInfo(p string) (FlacStreamInfo, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return FlacStreamInfo{}, err
+	}
+	defer f.Close()
+
+	err = skipID3(f)
+	if err != nil {
+		return FlacStreamInfo{}, fmt.Errorf("%s: %w", p, err)
+	}
+
+	// NOTE(patrik): "fLaC" + metadata block header + STREAMINFO (34 bytes)
+	data := make([]byte, 4+4+34)
+	_, err = io.ReadFull(f, data)
+	if err != nil {
+		return FlacStreamInfo{}, fmt.Errorf("%s: %w", p, err)
+	}
+
+	if string(data[:4]) != "fLaC" {
+		return FlacStreamInfo{}, fmt.Errorf("%s: %w", p, ErrNotFlac)
+	}
+
+	if data[4]&0x7f != 0 {
+		return FlacStreamInfo{}, fmt.Errorf("%s: first metadata block is not STREAMINFO", p)
+	}
+
+	info := data[8:]
+	bits := binary.BigEndian.Uint64(info[10:18])
+
+	res := FlacStreamInfo{
+		SampleRate:    int(bits >> 44),
+		Channels:      int((bits>>41)&0x7) + 1,
+		BitsPerSample: int((bits>>36)&0x1f) + 1,
+		TotalSamples:  int64(bits & 0xfffffffff),
+	}
+	copy(res.MD5[:], info[18:34])
+
+	return res, nil
+}
+
+func pcmFormat(bitsPerSample int) string {
+	switch bitsPerSample {
+	case 8:
+		return "s8"
+	case 16:
+		return "s16le"
+	case 24:
+		return "s24le"
+	case 32:
+		return "s32le"
+	}
+
+	return ""
+}
+
+// decode runs ffmpeg with the output written to w, errors reported by the
+// decoder is returned as an error
+func decode(p string, w io.Writer, outputArgs ...string) error {
+	args := []string{"-v", "error", "-i", p, "-map", "0:a:0"}
+	args = append(args, outputArgs...)
+
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = w
+
+	stderr := utils.CaptureStderr(cmd)
+
+	err := stderr.Done(cmd.Run())
+	if err != nil {
+		return fmt.Errorf("decode failed: %w", err)
+	}
+
+	if msg := stderr.String(); msg != "" {
+		return fmt.Errorf("decode errors: %s", msg)
+	}
+
+	return nil
+}
+
+// DecodeCheck fully decodes the file and reports any decode errors
+func DecodeCheck(p string) error {
+	return decode(p, io.Discard, "-f", "null", "-")
+}
+
+// ErrMD5Unavailable is returned when the file can't be verified with the
+// stored MD5, a full decode check can be used instead
+var ErrMD5Unavailable = errors.New("MD5 check unavailable")
+
+// VerifyFlacMD5 decodes the flac file and compares the MD5 of the decoded
+// samples with the one stored in STREAMINFO
+func VerifyFlacMD5(p string) error {
+	info, err := ReadFlacStreamInfo(p)
+	if err != nil {
+		return err
+	}
+
+	if !info.HasMD5() {
+		return fmt.Errorf("%w: no MD5 stored in STREAMINFO", ErrMD5Unavailable)
+	}
+
+	format := pcmFormat(info.BitsPerSample)
+	if format == "" {
+		return fmt.Errorf("%w: unsupported bits per sample %d", ErrMD5Unavailable, info.BitsPerSample)
+	}
+
+	hash := md5.New()
+	err = decode(p, hash, "-f", format, "-")
+	if err != nil {
+		return err
+	}
+
+	sum := hash.Sum(nil)
+	if !bytes.Equal(sum, info.MD5[:]) {
+		return fmt.Errorf("MD5 mismatch: expected %s got %s", hex.EncodeToString(info.MD5[:]), hex.EncodeToString(sum))
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const File = "checksums.toml"
+
+type Entry struct {
+	SHA256  string `toml:"sha256"`
+	Size    int64  `toml:"size"`
+	ModTime int64  `toml:"modtime"`
+}
+
+// Checksums is stored next to album.toml, the entries is keyed by the file
+// name relative to the album directory
+type Checksums struct {
+	Files map[string]Entry `toml:"files"`
+}
+
+func Load(dir string) (Checksums, error) {
+	p := path.Join(dir, File)
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Checksums{Files: make(map[string]Entry)}, nil
+		}
+
+		return Checksums{}, err
+	}
+
+	var res Checksums
+	err = toml.Unmarshal(data, &res)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("%s: %w", p, err)
+	}
+
+	if res.Files == nil {
+		res.Files = make(map[string]Entry)
+	}
+
+	return res, nil
+}
+
+func (c *Checksums) Save(dir string) error {
+	data, err := toml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(dir, File), data, 0644)
+}
+
+func Compute(p string) (Entry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return Entry{
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+		Size:    stat.Size(),
+		ModTime: stat.ModTime().Unix(),
+	}, nil
+}
+
+const (
+	StatusOK = iota
+	StatusMismatch
+	StatusMissing
+)
+
+// Check returns the status of the file compared to the stored entry
+func Check(p string, entry Entry) (int, error) {
+	res, err := Compute(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return StatusMissing, nil
+		}
+
+		return 0, err
+	}
+
+	if res.Size != entry.Size || res.SHA256 != entry.SHA256 {
+		return StatusMismatch, nil
+	}
+
+	return StatusOK, nil
+}
+
+// IsModified reports if the file has been changed on purpose since the
+// entry was created, bit rot doesn't change the size or modification time
+func IsModified(p string, entry Entry) (bool, error) {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return false, err
+	}
+
+	return stat.Size() != entry.Size || stat.ModTime().Unix() != entry.ModTime, nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+// NOTE(patrik): Checked in order, front is last since the other scans is
+// often named like "cover back" or "cover cd"
+var artworkNames = []struct {
+	kind  string
+	names []string
+}{
+	{types.ArtworkBack, []string{"back", "rear", "tray"}},
+	{types.ArtworkDisc, []string{"disc", "disk", "cd", "vinyl", "media"}},
+	{types.ArtworkBooklet, []string{"booklet", "inlay", "inside", "insert", "page", "scan"}},
+	{types.ArtworkArtist, []string{"artist", "band"}},
+	{types.ArtworkFront, []string{"front", "cover", "folder"}},
+}
+
+// nameWords splits the name into lowercase words, digits and punctuation
+// separates the words so "cd1" and "page_01" is "cd" and "page"
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// hasWord reports if any of the words is one of the names, plurals like
+// "scans" is matched as well
+func hasWord(words []string, names ...string) bool {
+	for _, w := range words {
+		for _, n := range names {
+			if w == n || w == n+"s" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// classifyArtwork returns the artwork type from the file name, empty if
+// the type couldn't be decided
+func classifyArtwork(name string) string {
+	if strings.EqualFold(path.Ext(name), ".pdf") {
+		return types.ArtworkBooklet
+	}
+
+	// NOTE(patrik): Whole words only, "Arcade" and "homepage" is not disc
+	// or booklet scans
+	words := nameWords(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+
+	for _, artwork := range artworkNames {
+		if hasWord(words, artwork.names...) {
+			return artwork.kind
+		}
+	}
+
+	// NOTE(patrik): Unnamed images inside the scan folders is usually
+	// booklet pages
+	dir := path.Dir(name)
+	if dir != "." && hasWord(nameWords(dir), "scan", "artwork", "booklet") {
+		return types.ArtworkBooklet
+	}
+
+	return ""
+}
+
+// findArtwork returns the artwork inside the album directory except the
+// cover
+func findArtwork(src, coverArt string) []types.Artwork {
+	var res []types.Artwork
+
+	for _, name := range utils.FindArtworkFiles(src) {
+		if name == coverArt {
+			continue
+		}
+
+		kind := classifyArtwork(name)
+		if kind == "" {
+			continue
+		}
+
+		res = append(res, types.Artwork{
+			Type: kind,
+			Path: name,
+		})
+	}
+
+	return res
+}
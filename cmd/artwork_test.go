@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/nanoteck137/slurpuff/types"
+)
+
+func TestClassifyArtwork(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+	}{
+		{"back.jpg", types.ArtworkBack},
+		{"Cover (Back).jpg", types.ArtworkBack},
+		{"cd1.jpg", types.ArtworkDisc},
+		{"Disc 2.png", types.ArtworkDisc},
+		{"Scans/page_01.jpg", types.ArtworkBooklet},
+		{"Booklet.pdf", types.ArtworkBooklet},
+		{"Scans/IMG_0001.jpg", types.ArtworkBooklet},
+		{"Artwork/0002.jpg", types.ArtworkBooklet},
+		{"front.jpg", types.ArtworkFront},
+		{"Folder.jpg", types.ArtworkFront},
+		{"Band Photo.jpg", types.ArtworkArtist},
+		{"Arcade.jpg", ""},
+		{"homepage.png", ""},
+		{"Mediation.jpg", ""},
+		{"Abcd/IMG_0001.jpg", ""},
+		{"Landscape/IMG_0001.jpg", ""},
+	}
+
+	for _, test := range tests {
+		kind := classifyArtwork(test.name)
+		if kind != test.kind {
+			t.Errorf("classifyArtwork(%q) = %q, expected %q", test.name, kind, test.kind)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/nanoteck137/slurpuff/checksum"
+	"github.com/nanoteck137/slurpuff/fingerprint"
+	"github.com/spf13/cobra"
+)
+
+// NOTE(patrik): Files created by us that shouldn't be reported as untracked
+var sidecarFiles = map[string]bool{
+	"album.toml":          true,
+	"old_album.toml":      true,
+	checksum.File:         true,
+	fingerprint.CacheFile: true,
+}
+
+func untrackedFiles(dir string, tracked map[string]checksum.Entry) []string {
+	var res []string
+
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if d.Name()[0] == '.' && p != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+
+		rel = filepath.ToSlash(rel)
+		if sidecarFiles[rel] {
+			return nil
+		}
+
+		if _, exists := tracked[rel]; !exists {
+			res = append(res, rel)
+		}
+
+		return nil
+	})
+
+	return res
+}
+
+func updateChecksums(dir string) {
+	metadata := readAlbumMetadata(path.Join(dir, "album.toml"))
+
+	checksums, err := checksum.Load(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	files := make(map[string]checksum.Entry)
+	added, updated := 0, 0
+
+	for _, name := range metadata.Files() {
+		p := path.Join(dir, name)
+
+		entry, exists := checksums.Files[name]
+		if exists {
+			modified, err := checksum.IsModified(p, entry)
+			if err != nil {
+				slog.Error("Failed to checksum", "file", p, "err", err)
+				files[name] = entry
+				continue
+			}
+
+			// NOTE(patrik): Never overwrite the checksum of a file that
+			// hasn't been modified, a mismatch is most likely corruption
+			if !modified {
+				files[name] = entry
+				continue
+			}
+		}
+
+		entry, err := checksum.Compute(p)
+		if err != nil {
+			slog.Error("Failed to checksum", "file", p, "err", err)
+			continue
+		}
+
+		files[name] = entry
+
+		if exists {
+			updated++
+		} else {
+			added++
+		}
+	}
+
+	removed := 0
+	for name := range checksums.Files {
+		if _, exists := files[name]; !exists {
+			removed++
+		}
+	}
+
+	checksums.Files = files
+	err = checksums.Save(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s: %d added, %d updated, %d removed\n", dir, added, updated, removed)
+}
+
+func verifyChecksums(dir string) bool {
+	checksums, err := checksum.Load(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var names []string
+	for name := range checksums.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatched, missing []string
+	for _, name := range names {
+		status, err := checksum.Check(path.Join(dir, name), checksums.Files[name])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		switch status {
+		case checksum.StatusMismatch:
+			mismatched = append(mismatched, name)
+		case checksum.StatusMissing:
+			missing = append(missing, name)
+		}
+	}
+
+	untracked := untrackedFiles(dir, checksums.Files)
+
+	ok := len(mismatched) == 0 && len(missing) == 0
+
+	status := "OK"
+	if !ok {
+		status = "FAILED"
+	}
+
+	fmt.Printf("%s: %s (%d files, %d mismatched, %d missing, %d untracked)\n", dir, status, len(names), len(mismatched), len(missing), len(untracked))
+
+	for _, name := range mismatched {
+		fmt.Printf("  mismatch:  %s\n", name)
+	}
+
+	for _, name := range missing {
+		fmt.Printf("  missing:   %s\n", name)
+	}
+
+	for _, name := range untracked {
+		fmt.Printf("  untracked: %s\n", name)
+	}
+
+	return ok
+}
+
+var checksumCmd = &cobra.Command{
+	Use:   "checksum",
+	Short: "Record and verify checksums of the album files",
+}
+
+var checksumUpdateCmd = &cobra.Command{
+	Use:   "update [album dirs...]",
+	Short: "Record checksums for new and modified files",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, dir := range getAlbumDirs(cmd, args) {
+			updateChecksums(dir)
+		}
+	},
+}
+
+var checksumVerifyCmd = &cobra.Command{
+	Use:   "verify [album dirs...]",
+	Short: "Verify the files against the recorded checksums",
+	Run: func(cmd *cobra.Command, args []string) {
+		ok := true
+		for _, dir := range getAlbumDirs(cmd, args) {
+			if !verifyChecksums(dir) {
+				ok = false
+			}
+		}
+
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	checksumCmd.PersistentFlags().BoolP("recursive", "r", false, "Recursively search for 'album.toml'")
+
+	checksumCmd.AddCommand(checksumUpdateCmd)
+	checksumCmd.AddCommand(checksumVerifyCmd)
+	rootCmd.AddCommand(checksumCmd)
+}
@@ -3,10 +3,10 @@ package cmd
 import (
 	"io/fs"
 	"log"
+	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/kr/pretty"
@@ -19,7 +19,7 @@ import (
 
 func Convert(p string) {
 	albumPath := path.Join(p, "album.toml")
-	log.Printf("Converting '%s'", albumPath)
+	slog.Info("Converting", "file", albumPath)
 
 	data, err := os.ReadFile(albumPath)
 	if err != nil {
@@ -32,14 +32,14 @@ func Convert(p string) {
 		log.Fatal(err)
 	}
 
-	pretty.Println(old)
+	slog.Debug("Old metadata", "metadata", pretty.Sprint(old))
 
 	var tracks []types.TrackMetadata
 	for _, t := range old.Tracks {
 		year := 0
 
 		if t.Date != "" {
-			y, err := strconv.Atoi(t.Date)
+			y, err := types.ParseYear(t.Date)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -67,7 +67,7 @@ func Convert(p string) {
 			dst := path.Join(p, name)
 
 			// TODO(patrik): Add options for this
-			err = parasect.RunFFmpeg(true, "-y", "-i", trackFile, "-vbr", "on", "-b:a", "128k", dst)
+			err = utils.RunFFmpeg("-y", "-i", trackFile, "-vbr", "on", "-b:a", "128k", dst)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -97,7 +97,7 @@ func Convert(p string) {
 	metadata.CoverArt = old.CoverArt
 	metadata.Tracks = tracks
 
-	pretty.Println(metadata)
+	slog.Debug("Converted metadata", "metadata", pretty.Sprint(metadata))
 
 	d, err := toml.Marshal(old)
 	if err != nil {
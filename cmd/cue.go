@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/cue"
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+// resolveCueFile returns the audio file the cue sheet refers to, the name
+// inside the sheet is often the wav file from before the rip was
+// compressed
+func resolveCueFile(name string, entries []os.DirEntry) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	base := strings.TrimSuffix(name, path.Ext(name))
+
+	for _, entry := range entries {
+		if entry.Name() == name {
+			return name
+		}
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if strings.TrimSuffix(entry.Name(), ext) == base && utils.IsValidTrackExt(ext) && !utils.IsLossyFormatExt(ext) {
+			return entry.Name()
+		}
+	}
+
+	return ""
+}
+
+type cueAlbum struct {
+	sheet  cue.Sheet
+	tracks []types.TrackMetadata
+
+	// Audio files split by the cue sheets
+	files map[string]bool
+}
+
+// readCueSheets creates the tracks for the lossless files described by
+// the cue sheets inside the directory, defaults is used for the fields
+// missing from the sheets
+func readCueSheets(src string, entries []os.DirEntry, defaults types.TrackMetadata, skipSpectrum bool) cueAlbum {
+	res := cueAlbum{
+		files: make(map[string]bool),
+	}
+
+	var sheets []cue.Sheet
+	for _, entry := range entries {
+		if entry.Name()[0] == '.' || !strings.EqualFold(path.Ext(entry.Name()), ".cue") {
+			continue
+		}
+
+		sheet, err := cue.ParseFile(path.Join(src, entry.Name()))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sheets = append(sheets, sheet)
+	}
+
+	if len(sheets) == 0 {
+		return res
+	}
+
+	res.sheet = sheets[0]
+
+	for i, sheet := range sheets {
+		disc := sheet.Disc
+		if disc == 0 && len(sheets) > 1 {
+			disc = i + 1
+		}
+
+		year := defaults.Year
+		if sheet.Year() != 0 {
+			year = sheet.Year()
+		}
+
+		genres := defaults.Genres
+		if sheet.Genre != "" {
+			genres = []string{sheet.Genre}
+		}
+
+		for _, file := range sheet.Files {
+			name := resolveCueFile(file.Name, entries)
+			if name == "" {
+				slog.Warn("Cue sheet file not found", "file", file.Name)
+				continue
+			}
+
+			// NOTE(patrik): Only lossless files can be split sample
+			// accurately, lossy files is handled as normal tracks
+			if utils.IsLossyFormatExt(path.Ext(name)) {
+				slog.Warn("Not splitting lossy file", "file", name)
+				continue
+			}
+
+			p := path.Join(src, name)
+
+			info, err := utils.GetInfo(p)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if info.SampleRate == 0 {
+				log.Fatalf("%s: unknown sample rate", p)
+			}
+
+			var spectrum *types.SpectrumCheck
+			if !skipSpectrum {
+				spectrum = checkSpectrum(p, info.SampleRate)
+			}
+
+			res.files[name] = true
+
+			for j, track := range file.Tracks {
+				start, _ := track.Start()
+
+				// NOTE(patrik): The pregap of the next track (INDEX 00)
+				// is kept at the end of the previous track
+				end := int64(0)
+				if j+1 < len(file.Tracks) {
+					end, _ = file.Tracks[j+1].Start()
+				}
+
+				startSample := cue.FramesToSamples(start, info.SampleRate)
+				endSample := int64(0)
+
+				duration := info.Duration - int(start/cue.FramesPerSecond)
+				if end != 0 {
+					endSample = cue.FramesToSamples(end, info.SampleRate)
+					duration = int((end - start) / cue.FramesPerSecond)
+				}
+
+				title := track.Title
+				if title == "" {
+					title = fmt.Sprintf("Track %02d", track.Number)
+				}
+
+				artist := track.Performer
+				if artist == "" {
+					artist = sheet.Performer
+				}
+
+				res.tracks = append(res.tracks, types.TrackMetadata{
+					Num:      track.Number,
+					Disc:     disc,
+					Name:     title,
+					Duration: duration,
+					Artist:   artist,
+					Year:     year,
+					Tags:     defaults.Tags,
+					Genres:   genres,
+					File: types.TrackFile{
+						Lossless: name,
+						Start:    startSample,
+						End:      endSample,
+					},
+					ISRC:     track.ISRC,
+					Spectrum: spectrum,
+				})
+			}
+		}
+	}
+
+	return res
+}
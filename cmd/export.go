@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path"
+
+	"github.com/nanoteck137/slurpuff/album"
+	"github.com/nanoteck137/slurpuff/cover"
+	"github.com/nanoteck137/slurpuff/destination"
+	"github.com/nanoteck137/slurpuff/progress"
+	"github.com/nanoteck137/slurpuff/single"
+	"github.com/spf13/cobra"
+)
+
+// addExportFlags adds the flags used to create the export options, shared
+// between the commands exporting albums
+func addExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("mode", "m", album.ModeDwebble, "export mode (dwebble, opus, mp3, aac, map, lossless-portable)")
+	cmd.Flags().String("template", "", "output path template (default is the template of the mode in the config or "+album.DefaultTemplate+")")
+	cmd.Flags().String("various-artists", album.DefaultVariousArtists, "artist folder used for compilations")
+	cmd.Flags().String("sanitize", "", "filename sanitize profile (posix, windows, fat32-safe, ascii-only)")
+	cmd.Flags().Int("max-path-length", 0, "maximum output path length (0 for no limit)")
+
+	cmd.Flags().Bool("allow-lossy-transcode", false, "allow transcoding lossy sources to another lossy codec")
+
+	cmd.Flags().String("resampler", "", "resampler used for sample rate and bit depth conversion (soxr, swr)")
+	cmd.Flags().Int("max-sample-rate", 0, "maximum output sample rate (0 keeps the limit of the mode)")
+	cmd.Flags().Int("max-bit-depth", 0, "maximum output bit depth, 16 or 24 (0 keeps the limit of the mode)")
+
+	cmd.Flags().String("progress", progress.ModeAuto, "progress display (auto, tty, plain, none)")
+}
+
+// newProgress creates the progress tracker for the progress flag, --quiet
+// hides the progress unless the flag is set
+func newProgress(cmd *cobra.Command) *progress.Tracker {
+	mode, _ := cmd.Flags().GetString("progress")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+
+	if quiet && !cmd.Flags().Changed("progress") {
+		mode = progress.ModeNone
+	}
+
+	if !progress.IsValidMode(mode) {
+		log.Fatalf("unknown progress mode: %s", mode)
+	}
+
+	return progress.New(os.Stdout, mode)
+}
+
+// getExportOptions creates the export options from the flags added by
+// addExportFlags, the config for the mode is used for the flags not set
+func getExportOptions(cmd *cobra.Command) album.Options {
+	mode, _ := cmd.Flags().GetString("mode")
+	template, _ := cmd.Flags().GetString("template")
+	variousArtists, _ := cmd.Flags().GetString("various-artists")
+	sanitize, _ := cmd.Flags().GetString("sanitize")
+	maxPathLength, _ := cmd.Flags().GetInt("max-path-length")
+	allowLossyTranscode, _ := cmd.Flags().GetBool("allow-lossy-transcode")
+	resampler, _ := cmd.Flags().GetString("resampler")
+	maxSampleRate, _ := cmd.Flags().GetInt("max-sample-rate")
+	maxBitDepth, _ := cmd.Flags().GetInt("max-bit-depth")
+
+	conf := loadConfig(cmd)
+	modeConf := conf.Mode(mode)
+
+	if template == "" {
+		template = modeConf.Template
+	}
+
+	if sanitize == "" {
+		sanitize = modeConf.Sanitize
+	}
+
+	if !cmd.Flags().Changed("max-path-length") {
+		maxPathLength = modeConf.MaxPathLength
+	}
+
+	if resampler == "" {
+		resampler = modeConf.Resampler
+	}
+
+	if !cmd.Flags().Changed("allow-lossy-transcode") {
+		allowLossyTranscode = modeConf.AllowLossyTranscode
+	}
+
+	if !cmd.Flags().Changed("max-sample-rate") {
+		maxSampleRate = modeConf.MaxSampleRate
+	}
+
+	if !cmd.Flags().Changed("max-bit-depth") {
+		maxBitDepth = modeConf.MaxBitDepth
+	}
+
+	return album.Options{
+		Mode:           mode,
+		Template:       template,
+		VariousArtists: variousArtists,
+		Sanitize:       sanitize,
+		MaxPathLength:  maxPathLength,
+
+		AllowLossyTranscode: allowLossyTranscode,
+		Resampler:           resampler,
+		MaxSampleRate:       maxSampleRate,
+		MaxBitDepth:         maxBitDepth,
+
+		Cover: cover.Options{
+			MaxSize:      modeConf.Cover.MaxSize,
+			EmbedMaxSize: modeConf.Cover.EmbedMaxSize,
+			Quality:      modeConf.Cover.Quality,
+			Thumbnails:   modeConf.Cover.Thumbnails,
+		},
+		ArtworkTypes: modeConf.ArtworkTypes,
+
+		Progress: newProgress(cmd),
+	}
+}
+
+// openDestination opens the destination for the dst flag, returns the
+// destination and the path inside it
+func openDestination(dst string) (destination.Destination, string) {
+	d, p, err := destination.Open(dst)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return d, p
+}
+
+// exportDir exports the album or the singles inside src
+func exportDir(opts album.Options, src, dst string) error {
+	_, err := os.Stat(path.Join(src, "singles.toml"))
+	if err == nil {
+		return single.Execute(opts, src, dst)
+	}
+
+	return album.Execute(opts, src, dst)
+}
+
+var exportCmd = &cobra.Command{
+	Use: "export",
+	Run: func(cmd *cobra.Command, args []string) {
+		src, _ := cmd.Flags().GetString("dir")
+		dst, _ := cmd.Flags().GetString("dst")
+
+		opts := getExportOptions(cmd)
+
+		d, dst := openDestination(dst)
+		defer d.Close()
+
+		opts.Destination = d
+
+		err := exportDir(opts, src, dst)
+		opts.Progress.Finish()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringP("dir", "d", ".", "album directory")
+	exportCmd.Flags().String("dst", "", "output directory or sftp://[user@]host[:port]/path")
+	addExportFlags(exportCmd)
+
+	exportCmd.MarkFlagRequired("dst")
+
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"path"
+	"sort"
+
+	"github.com/nanoteck137/slurpuff/fingerprint"
+	"github.com/spf13/cobra"
+)
+
+type fingerprintedTrack struct {
+	file        string
+	duration    int
+	fingerprint []uint32
+}
+
+// fingerprintAlbum returns the fingerprints for the tracks inside the
+// album, missing or outdated fingerprints is calculated and cached
+func fingerprintAlbum(dir string) ([]fingerprintedTrack, error) {
+	metadata := readAlbumMetadata(path.Join(dir, "album.toml"))
+
+	cache, err := fingerprint.LoadCache(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+
+	var res []fingerprintedTrack
+	for _, track := range metadata.Tracks {
+		name := track.SourceFile()
+		if name == "" {
+			continue
+		}
+
+		// NOTE(patrik): Split tracks is fingerprinted from their range of
+		// the lossless file, not the start of the whole file
+		fp, updated, err := cache.Get(dir, name, track.File.Start, track.File.End)
+		if err != nil {
+			return nil, err
+		}
+
+		if updated {
+			slog.Info("Fingerprinted", "file", path.Join(dir, name))
+			changed = true
+		}
+
+		file := path.Join(dir, name)
+		if track.File.IsSplit() {
+			file = fmt.Sprintf("%s (track %d)", file, track.Num)
+		}
+
+		res = append(res, fingerprintedTrack{
+			file:        file,
+			duration:    track.Duration,
+			fingerprint: fp,
+		})
+	}
+
+	if changed {
+		err := cache.Save(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint [album dirs...]",
+	Short: "Calculate and cache audio fingerprints for albums",
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, dir := range getAlbumDirs(cmd, args) {
+			_, err := fingerprintAlbum(dir)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+// NOTE(patrik): Tracks with durations further apart than this is never the
+// same recording, skips most of the comparisons
+const dupesMaxDurationDiff = 10
+
+var dupesCmd = &cobra.Command{
+	Use:   "dupes [library]",
+	Short: "Report tracks with matching fingerprints",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+
+		var tracks []fingerprintedTrack
+		for _, dir := range findAlbumDirs(root) {
+			res, err := fingerprintAlbum(dir)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			tracks = append(tracks, res...)
+		}
+
+		parent := make([]int, len(tracks))
+		for i := range parent {
+			parent[i] = i
+		}
+
+		var find func(i int) int
+		find = func(i int) int {
+			if parent[i] != i {
+				parent[i] = find(parent[i])
+			}
+
+			return parent[i]
+		}
+
+		scores := make(map[int]float64)
+
+		for i := range tracks {
+			for j := i + 1; j < len(tracks); j++ {
+				a, b := tracks[i], tracks[j]
+
+				if a.duration != 0 && b.duration != 0 {
+					diff := a.duration - b.duration
+					if diff < -dupesMaxDurationDiff || diff > dupesMaxDurationDiff {
+						continue
+					}
+				}
+
+				score := fingerprint.Similarity(a.fingerprint, b.fingerprint)
+				if score < threshold {
+					continue
+				}
+
+				parent[find(j)] = find(i)
+				scores[i] = max(scores[i], score)
+				scores[j] = max(scores[j], score)
+			}
+		}
+
+		groups := make(map[int][]int)
+		for i := range tracks {
+			root := find(i)
+			groups[root] = append(groups[root], i)
+		}
+
+		var roots []int
+		for root, members := range groups {
+			if len(members) > 1 {
+				roots = append(roots, root)
+			}
+		}
+		sort.Ints(roots)
+
+		for n, root := range roots {
+			fmt.Printf("Duplicate group %d:\n", n+1)
+			for _, i := range groups[root] {
+				fmt.Printf("  %.2f  %s\n", scores[i], tracks[i].file)
+			}
+		}
+
+		if len(roots) == 0 {
+			fmt.Println("No duplicates found")
+		}
+	},
+}
+
+func init() {
+	fingerprintCmd.Flags().BoolP("recursive", "r", false, "Recursively search for 'album.toml' to fingerprint")
+
+	dupesCmd.Flags().Float64("threshold", 0.8, "minimum similarity for tracks to count as duplicates (0.0 - 1.0)")
+
+	rootCmd.AddCommand(fingerprintCmd)
+	rootCmd.AddCommand(dupesCmd)
+}
@@ -2,19 +2,70 @@ package cmd
 
 import (
 	"log"
+	"log/slog"
 	"os"
 	"path"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/nanoteck137/parasect"
 	"github.com/nanoteck137/slurpuff/types"
 	"github.com/nanoteck137/slurpuff/utils"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
 )
 
+// firstTag returns the first tag found, some tags is named differently
+// depending on the container
+func firstTag(tags map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, exists := tags[name]; exists {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// extractEmbeddedCover writes the first picture embedded inside the
+// tracks to the album directory, returns the name of the written file or
+// empty if no track has a picture
+func extractEmbeddedCover(src string, tracks []types.TrackMetadata) string {
+	seen := make(map[string]bool)
+
+	for _, track := range tracks {
+		for _, name := range []string{track.File.Lossless, track.File.Lossy} {
+			if name == "" || seen[name] {
+				continue
+			}
+
+			seen[name] = true
+
+			p := path.Join(src, name)
+
+			info, err := utils.GetInfo(p)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if info.Picture == nil {
+				continue
+			}
+
+			cover, err := utils.ExtractEmbeddedCover(p, info.Picture, src)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			slog.Info("Extracted embedded cover", "file", name, "cover", cover)
+
+			return cover
+		}
+	}
+
+	return ""
+}
+
 var initCmd = &cobra.Command{
 	Use: "init",
 
@@ -26,6 +77,12 @@ var initCmd = &cobra.Command{
 		genres, _ := cmd.Flags().GetString("genres")
 		tags, _ := cmd.Flags().GetString("tags")
 		yearOverride, _ := cmd.Flags().GetInt("year")
+		releaseType, _ := cmd.Flags().GetString("type")
+		skipSpectrum, _ := cmd.Flags().GetBool("skip-spectrum")
+
+		if releaseType != "" && !types.IsValidReleaseType(releaseType) {
+			log.Fatalf("Unknown release type: %s", releaseType)
+		}
 
 		entries, err := os.ReadDir(src)
 		if err != nil {
@@ -33,7 +90,14 @@ var initCmd = &cobra.Command{
 		}
 
 		albumArtist := ""
+		albumArtistSort := ""
 		albumName := ""
+		albumSort := ""
+		releaseID := ""
+		releaseGroupID := ""
+		albumArtistID := ""
+		barcode := ""
+		compilation := false
 
 		var defaultGenres []string
 		if genres != "" {
@@ -51,12 +115,38 @@ var initCmd = &cobra.Command{
 			}
 		}
 
-		var tracks []types.TrackMetadata
+		cueYear := time.Now().Year()
+		if yearOverride != 0 {
+			cueYear = yearOverride
+		}
+
+		cueAlbum := readCueSheets(src, entries, types.TrackMetadata{
+			Year:   cueYear,
+			Tags:   defaultTags,
+			Genres: defaultGenres,
+		}, skipSpectrum)
+
+		albumName = cueAlbum.sheet.Title
+		albumArtist = cueAlbum.sheet.Performer
+		barcode = cueAlbum.sheet.Catalog
+
+		tracks := cueAlbum.tracks
+		for i := range tracks {
+			if yearOverride != 0 {
+				tracks[i].Year = yearOverride
+			}
+		}
+
 		for _, entry := range entries {
 			if entry.Name()[0] == '.' {
 				continue
 			}
 
+			// NOTE(patrik): Already split into tracks by a cue sheet
+			if cueAlbum.files[entry.Name()] {
+				continue
+			}
+
 			p := path.Join(src, entry.Name())
 			ext := path.Ext(entry.Name())
 
@@ -81,6 +171,28 @@ var initCmd = &cobra.Command{
 				}
 			}
 
+			if albumSort == "" {
+				albumSort = firstTag(info.Tags, "albumsort", "album-sort", "sort_album")
+			}
+
+			if releaseID == "" {
+				releaseID = firstTag(info.Tags, "musicbrainz_albumid", "musicbrainz album id")
+				releaseGroupID = firstTag(info.Tags, "musicbrainz_releasegroupid", "musicbrainz release group id")
+				albumArtistID = firstTag(info.Tags, "musicbrainz_albumartistid", "musicbrainz album artist id")
+			}
+
+			if barcode == "" {
+				barcode = firstTag(info.Tags, "barcode")
+			}
+
+			if albumArtistSort == "" {
+				albumArtistSort = firstTag(info.Tags, "albumartistsort", "album_artist-sort", "sort_album_artist")
+			}
+
+			if value, exists := info.Tags["compilation"]; exists && value == "1" {
+				compilation = true
+			}
+
 			artist := ""
 			if value, exists := info.Tags["artist"]; exists {
 				artist = value
@@ -125,6 +237,7 @@ var initCmd = &cobra.Command{
 
 			lossless := entry.Name()
 			lossy := ""
+			var spectrum *types.SpectrumCheck
 
 			if utils.IsLossyFormatExt(ext) {
 				lossless = ""
@@ -133,41 +246,90 @@ var initCmd = &cobra.Command{
 				dst := strings.TrimSuffix(entry.Name(), ext) + ".opus"
 
 				// TODO(patrik): Add options for this
-				err = parasect.RunFFmpeg(true, "-y", "-i", entry.Name(), "-vbr", "on", "-b:a", "128k", dst)
+				err = utils.RunFFmpeg("-y", "-i", entry.Name(), "-vbr", "on", "-b:a", "128k", dst)
 				if err != nil {
 					log.Fatal(err)
 				}
 
 				lossy = dst
+
+				if !skipSpectrum {
+					spectrum = checkSpectrum(p, info.SampleRate)
+				}
 			}
 
 			tracks = append(tracks, types.TrackMetadata{
-				Num:       int(track),
-				Name:      name,
-				Duration:  info.Duration,
-				Artist:    artists[0],
-				Year:      year,
-				Tags:      defaultTags,
-				Genres:    genres,
-				Featuring: artists[1:],
+				Num:        int(track),
+				Name:       name,
+				NameSort:   firstTag(info.Tags, "titlesort", "title-sort", "sort_name"),
+				Duration:   info.Duration,
+				Artist:     artists[0],
+				ArtistSort: firstTag(info.Tags, "artistsort", "artist-sort", "sort_artist"),
+				Year:       year,
+				Tags:       defaultTags,
+				Genres:     genres,
+				Featuring:  artists[1:],
 				File: types.TrackFile{
 					Lossless: lossless,
 					Lossy:    lossy,
 				},
+				MusicBrainzRecordingID: firstTag(info.Tags, "musicbrainz_trackid", "musicbrainz track id"),
+				MusicBrainzArtistID:    firstTag(info.Tags, "musicbrainz_artistid", "musicbrainz artist id"),
+				ISRC:                   firstTag(info.Tags, "isrc", "tsrc"),
+				Spectrum:               spectrum,
 			})
 		}
 
+		// NOTE(patrik): Without an album artist tag we can't trust the
+		// first track artist to be the album artist if the tracks has
+		// different artists
 		if albumArtist == "" && len(tracks) > 0 {
-			albumArtist = tracks[0].Artist
+			for _, track := range tracks {
+				if track.Artist != tracks[0].Artist {
+					compilation = true
+					break
+				}
+			}
+
+			if !compilation {
+				albumArtist = tracks[0].Artist
+			}
 		}
 
-		albumCover := utils.FindFirstValidImage(src)
+		if releaseType == "" {
+			switch {
+			case compilation:
+				releaseType = types.ReleaseTypeCompilation
+			case len(tracks) == 1:
+				releaseType = types.ReleaseTypeSingle
+			default:
+				releaseType = types.ReleaseTypeAlbum
+			}
+		}
+
+		albumCover := utils.FindBestImage(src)
+
+		// NOTE(patrik): Use the picture embedded inside the tracks when
+		// there is no image that looks like the front cover
+		if albumCover == "" {
+			albumCover = extractEmbeddedCover(src, tracks)
+		}
 
 		config := types.AlbumMetadata{
-			Album:    albumName,
-			Artist:   albumArtist,
-			CoverArt: albumCover,
-			Tracks:   tracks,
+			Album:      albumName,
+			AlbumSort:  albumSort,
+			Artist:     albumArtist,
+			ArtistSort: albumArtistSort,
+			Type:       releaseType,
+			CoverArt:   albumCover,
+			Artwork:    findArtwork(src, albumCover),
+
+			MusicBrainzReleaseID:      releaseID,
+			MusicBrainzReleaseGroupID: releaseGroupID,
+			MusicBrainzArtistID:       albumArtistID,
+			Barcode:                   barcode,
+
+			Tracks: tracks,
 		}
 
 		data, err := toml.Marshal(config)
@@ -189,6 +351,8 @@ func init() {
 	initCmd.Flags().String("genres", "", "set genres (comma seperated list)")
 	initCmd.Flags().String("tags", "", "set tags (comma seperated list)")
 	initCmd.Flags().Int("year", 0, "override year")
+	initCmd.Flags().String("type", "", "set release type (album, ep, single, compilation, soundtrack, live)")
+	initCmd.Flags().Bool("skip-spectrum", false, "skip the fake lossless check of the lossless files")
 
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"path"
+
+	"github.com/nanoteck137/slurpuff/audio"
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/nanoteck137/slurpuff/utils"
+	"github.com/spf13/cobra"
+)
+
+// checkSpectrum runs the fake lossless check on the file, returns nil if
+// the file couldn't be analyzed
+func checkSpectrum(p string, sampleRate int) *types.SpectrumCheck {
+	res, err := audio.AnalyzeFile(p, sampleRate)
+	if err != nil {
+		slog.Error("Spectrum check failed", "file", p, "err", err)
+		return nil
+	}
+
+	if res.Suspect() {
+		slog.Warn("Hard cutoff, might be transcoded from a lossy source", "file", p, "cutoff", res.Cutoff)
+	}
+
+	return &types.SpectrumCheck{
+		Verdict: res.Verdict(),
+		Cutoff:  res.Cutoff,
+	}
+}
+
+func formatCutoff(cutoff int) string {
+	if cutoff == 0 {
+		return "no cutoff"
+	}
+
+	return fmt.Sprintf("cutoff %.1f kHz", float64(cutoff)/1000)
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [album dirs...]",
+	Short: "Check the lossless files for signs of lossy sources",
+	Run: func(cmd *cobra.Command, args []string) {
+		update, _ := cmd.Flags().GetBool("update")
+
+		for _, dir := range getAlbumDirs(cmd, args) {
+			albumPath := path.Join(dir, "album.toml")
+			metadata := readAlbumMetadata(albumPath)
+
+			fmt.Printf("%s:\n", dir)
+
+			// NOTE(patrik): Split tracks shares the same file
+			results := make(map[string]audio.SpectrumResult)
+
+			for i, track := range metadata.Tracks {
+				if track.File.Lossless == "" {
+					fmt.Printf("  %02d  %-8s  %s (no lossless file)\n", track.Num, "-", track.Name)
+					continue
+				}
+
+				res, analyzed := results[track.File.Lossless]
+				if !analyzed {
+					p := path.Join(dir, track.File.Lossless)
+
+					info, err := utils.GetInfo(p)
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					res, err = audio.AnalyzeFile(p, info.SampleRate)
+					if err != nil {
+						fmt.Printf("  %02d  %-8s  %s (%v)\n", track.Num, "error", track.Name, err)
+						continue
+					}
+
+					results[track.File.Lossless] = res
+				}
+
+				fmt.Printf("  %02d  %-8s  %s (%s, %d Hz)\n", track.Num, res.Verdict(), track.Name, formatCutoff(res.Cutoff), res.SampleRate)
+
+				metadata.Tracks[i].Spectrum = &types.SpectrumCheck{
+					Verdict: res.Verdict(),
+					Cutoff:  res.Cutoff,
+				}
+			}
+
+			if update {
+				writeAlbumMetadata(albumPath, metadata)
+			}
+		}
+	},
+}
+
+func init() {
+	inspectCmd.Flags().BoolP("recursive", "r", false, "Recursively search for 'album.toml' to inspect")
+	inspectCmd.Flags().Bool("update", false, "record the results in album.toml")
+
+	rootCmd.AddCommand(inspectCmd)
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"path"
+	"strconv"
+
+	"github.com/kr/pretty"
+	"github.com/nanoteck137/slurpuff/lookup"
+	"github.com/nanoteck137/slurpuff/utils"
+	"github.com/spf13/cobra"
+)
+
+func getProvider(cmd *cobra.Command) lookup.Provider {
+	provider, _ := cmd.Flags().GetString("provider")
+	index, _ := cmd.Flags().GetString("index")
+
+	switch provider {
+	case "musicbrainz-dump":
+		return &lookup.MusicBrainzDump{
+			Index: index,
+		}
+	default:
+		log.Fatalf("Unknown lookup provider: %s", provider)
+	}
+
+	return nil
+}
+
+const maxShownMatches = 5
+
+var lookupCmd = &cobra.Command{
+	Use:   "lookup",
+	Short: "Lookup the album release and update album.toml",
+	Run: func(cmd *cobra.Command, args []string) {
+		src, _ := cmd.Flags().GetString("dir")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		albumPath := path.Join(src, "album.toml")
+		metadata := readAlbumMetadata(albumPath)
+
+		for i, track := range metadata.Tracks {
+			if track.Duration != 0 {
+				continue
+			}
+
+			info, err := utils.GetInfo(path.Join(src, track.SourceFile()))
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			metadata.Tracks[i].Duration = info.Duration
+		}
+
+		provider := getProvider(cmd)
+		query := lookup.QueryFromMetadata(metadata)
+
+		releases, err := provider.Search(query)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		matches := lookup.Rank(query, releases)
+		if len(matches) == 0 {
+			log.Fatalf("No release with %d tracks matched '%s'", len(query.Durations), albumPath)
+		}
+
+		shown := min(len(matches), maxShownMatches)
+		for i, match := range matches[:shown] {
+			r := match.Release
+			fmt.Printf("[%d] %s - %s (%d, %s) score: %.2f distance: %.1fs id: %s\n", i+1, r.Artist, r.Title, r.Year, r.Type, match.Score, match.Distance, r.ID)
+		}
+
+		selected := 0
+		if !yes {
+			answer := prompt(fmt.Sprintf("Select release [1-%d] (0 to abort, default 1): ", shown))
+			if answer != "" {
+				n, err := strconv.Atoi(answer)
+				if err != nil || n < 0 || n > shown {
+					log.Fatalf("Invalid selection: %s", answer)
+				}
+
+				if n == 0 {
+					return
+				}
+
+				selected = n - 1
+			}
+		}
+
+		updated := lookup.Apply(metadata, matches[selected].Release)
+
+		diff := pretty.Diff(metadata, updated)
+		if len(diff) == 0 {
+			fmt.Println("No changes")
+			return
+		}
+
+		for _, line := range diff {
+			fmt.Println("  " + line)
+		}
+
+		if !yes && !confirm(fmt.Sprintf("Write changes to '%s'?", albumPath)) {
+			return
+		}
+
+		writeAlbumMetadata(albumPath, updated)
+	},
+}
+
+var lookupImportCmd = &cobra.Command{
+	Use:   "import <release dump>",
+	Short: "Import a MusicBrainz JSON release dump for the lookup",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		index, _ := cmd.Flags().GetString("index")
+
+		count, err := lookup.ImportMusicBrainzDump(args[0], index)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		slog.Info("Imported releases", "count", count, "index", index)
+	},
+}
+
+func init() {
+	lookupCmd.PersistentFlags().String("index", lookup.DefaultMusicBrainzIndex(), "MusicBrainz index created by 'lookup import'")
+
+	lookupCmd.Flags().StringP("dir", "d", ".", "album directory")
+	lookupCmd.Flags().String("provider", "musicbrainz-dump", "lookup provider")
+	lookupCmd.Flags().BoolP("yes", "y", false, "use the best match and write without asking")
+
+	lookupCmd.AddCommand(lookupImportCmd)
+	rootCmd.AddCommand(lookupCmd)
+}
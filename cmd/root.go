@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"log"
+	"log/slog"
+	"os"
 
+	"github.com/nanoteck137/slurpuff/config"
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
 	Use: "slurpuff",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		setupLogging(cmd)
+	},
 }
 
 func Execute() {
@@ -16,3 +22,59 @@ func Execute() {
 		log.Fatal(err)
 	}
 }
+
+// setupLogging sets the default logger from the global flags, the log
+// package is written through the same logger at error level
+func setupLogging(cmd *cobra.Command) {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	format, _ := cmd.Flags().GetString("log-format")
+
+	level := slog.LevelInfo
+	switch {
+	case verbose:
+		level = slog.LevelDebug
+	case quiet:
+		level = slog.LevelWarn
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		log.Fatalf("unknown log format: %s", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	// NOTE(patrik): The log package is only used for log.Fatal, the
+	// messages should be shown even with --quiet
+	log.SetOutput(slog.NewLogLogger(handler, slog.LevelError).Writer())
+	log.SetFlags(0)
+}
+
+func loadConfig(cmd *cobra.Command) config.Config {
+	p, _ := cmd.Flags().GetString("config")
+
+	conf, err := config.Load(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return conf
+}
+
+func init() {
+	rootCmd.PersistentFlags().String("config", "", "config file (default is $XDG_CONFIG_HOME/slurpuff/config.toml)")
+
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "only log warnings and errors")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "log debug messages, including the ffmpeg and ffprobe output")
+	rootCmd.PersistentFlags().String("log-format", "text", "log format (text, json)")
+
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+}
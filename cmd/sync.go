@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nanoteck137/slurpuff/album"
+	"github.com/nanoteck137/slurpuff/destination"
+	"github.com/nanoteck137/slurpuff/single"
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/nanoteck137/slurpuff/utils"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+)
+
+const (
+	syncUnchanged = iota
+	syncAdded
+	syncUpdated
+)
+
+type syncItem struct {
+	src     string
+	config  types.AlbumMetadata
+	outputs []string
+	state   int
+}
+
+func (i *syncItem) name() string {
+	if i.config.Artist == "" {
+		return i.config.Album
+	}
+
+	return i.config.Artist + " - " + i.config.Album
+}
+
+// readExportConfigs returns the album configs inside the album or singles
+// directory and the config file they came from
+func readExportConfigs(dir string) ([]types.AlbumMetadata, string, error) {
+	singles := path.Join(dir, "singles.toml")
+	if _, err := os.Stat(singles); err == nil {
+		configs, err := single.ReadConfigs(dir)
+		return configs, singles, err
+	}
+
+	config, err := album.ReadConfig(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return []types.AlbumMetadata{config}, path.Join(dir, "album.toml"), nil
+}
+
+func modTime(p string) time.Time {
+	info, err := os.Stat(p)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// syncState compares the outputs with the sources, the outputs is out of
+// date if any of the sources has changed after the outputs was written
+func syncState(d destination.Destination, item *syncItem, conf string) int {
+	var newest time.Time
+	for _, name := range item.config.Files() {
+		if t := modTime(path.Join(item.src, name)); t.After(newest) {
+			newest = t
+		}
+	}
+
+	if t := modTime(conf); t.After(newest) {
+		newest = t
+	}
+
+	missing := 0
+	stale := false
+	for _, output := range item.outputs {
+		info, err := d.Stat(output)
+		if err != nil {
+			missing++
+			continue
+		}
+
+		if info.ModTime().Before(newest) {
+			stale = true
+		}
+	}
+
+	switch {
+	case missing == len(item.outputs):
+		return syncAdded
+	case missing > 0 || stale:
+		return syncUpdated
+	}
+
+	return syncUnchanged
+}
+
+// isInside reports if p is dir or inside dir
+func isInside(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// isExportedDir reports if dir has the folder metadata written by the
+// exporter, only those directories is removed as a whole
+func isExportedDir(d destination.Destination, dir string) bool {
+	f, err := d.Open(path.Join(dir, types.FolderMetadataFile))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var metadata types.ArtistFolderMetadata
+	err = toml.NewDecoder(f).Decode(&metadata)
+	return err == nil && metadata.Name != ""
+}
+
+// findStale returns the paths inside dst without a source. Exported
+// directories outside of the album folders is removed as a whole and only
+// the tracks is removed inside the album folders, everything else is left
+// alone.
+func findStale(d destination.Destination, dst string, albumDirs []string, outputs map[string]bool) []string {
+	var stale []string
+
+	// NOTE(patrik): Flat layouts puts the tracks directly inside dst, the
+	// other files inside dst isn't ours to remove
+	var dirs []string
+	for _, dir := range albumDirs {
+		if dir != dst {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	destination.Walk(d, dst, func(p string, info fs.FileInfo, err error) error {
+		if p == dst && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			slog.Error("Failed to read", "path", p, "err", err)
+			return nil
+		}
+
+		if p == dst {
+			return nil
+		}
+
+		inAlbum := false
+		isParent := false
+		for _, dir := range dirs {
+			if isInside(p, dir) {
+				inAlbum = true
+			}
+
+			if isInside(dir, p) {
+				isParent = true
+			}
+		}
+
+		if info.IsDir() {
+			switch {
+			case inAlbum || isParent:
+				return nil
+			case isExportedDir(d, p):
+				stale = append(stale, p)
+			}
+
+			return filepath.SkipDir
+		}
+
+		if inAlbum && utils.IsValidTrackExt(path.Ext(p)) && !outputs[p] {
+			stale = append(stale, p)
+		}
+
+		return nil
+	})
+
+	return stale
+}
+
+// findCollisions reports the tracks from different albums ending up with
+// the same output
+func findCollisions(items []*syncItem, dst, profile string) []string {
+	var problems []string
+	seen := make(map[string]string)
+
+	for _, item := range items {
+		for _, output := range item.outputs {
+			key := utils.NameKey(output, profile)
+			if other, exists := seen[key]; exists {
+				rel := strings.TrimPrefix(strings.TrimPrefix(output, dst), "/")
+				problems = append(problems, fmt.Sprintf("'%s' and '%s' both map to '%s'", other, item.name(), rel))
+				continue
+			}
+
+			seen[key] = item.name()
+		}
+	}
+
+	return problems
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <library> <dst>",
+	Short: "Export the whole library and remove the outputs without a source",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := args[0]
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+		prune, _ := cmd.Flags().GetBool("prune")
+
+		opts := getExportOptions(cmd)
+
+		d, dst := openDestination(args[1])
+		defer d.Close()
+
+		opts.Destination = d
+
+		var items []*syncItem
+		failed := 0
+
+		dirs, err := findExportDirs(root)
+		if err != nil && dirs == nil {
+			log.Fatal(err)
+		}
+
+		if err != nil {
+			slog.Error("Failed to search the library", "err", err)
+			failed++
+		}
+
+		if len(dirs) == 0 {
+			log.Fatalf("no album.toml or singles.toml found inside '%s'", root)
+		}
+
+		for _, dir := range dirs {
+			configs, conf, err := readExportConfigs(dir)
+			if err != nil {
+				slog.Error("Failed to read album", "dir", dir, "err", err)
+				failed++
+				continue
+			}
+
+			for _, config := range configs {
+				item := &syncItem{
+					src:    dir,
+					config: config,
+				}
+
+				item.outputs, err = album.Outputs(config, opts, dir, dst)
+				if err != nil {
+					slog.Error("Failed to plan export", "dir", dir, "album", item.name(), "err", err)
+					failed++
+					continue
+				}
+
+				item.state = syncState(d, item, conf)
+				if force && item.state == syncUnchanged {
+					item.state = syncUpdated
+				}
+
+				items = append(items, item)
+			}
+		}
+
+		// NOTE(patrik): Report the problems before anything is written,
+		// the same as the problems inside one album
+		problems := findCollisions(items, dst, opts.SanitizeProfile())
+		if len(problems) > 0 {
+			log.Fatalf("export problems:\n  %s", strings.Join(problems, "\n  "))
+		}
+
+		outputs := make(map[string]bool)
+		var albumDirs []string
+		for _, item := range items {
+			for _, output := range item.outputs {
+				outputs[output] = true
+			}
+
+			if len(item.outputs) > 0 {
+				albumDirs = append(albumDirs, album.AlbumDir(item.outputs))
+			}
+		}
+
+		verb := func(s string) string {
+			if dryRun {
+				return "Would " + strings.ToLower(s)
+			}
+
+			return s
+		}
+
+		var removed []string
+
+		// NOTE(patrik): The outputs of the albums we failed to read is
+		// unknown, pruning would remove them
+		switch {
+		case !prune:
+		case failed > 0:
+			slog.Warn("Skipping removal of stale outputs", "failed", failed)
+		default:
+			removed = findStale(d, dst, albumDirs, outputs)
+		}
+
+		for _, p := range removed {
+			fmt.Printf("%s '%s'\n", verb("Remove"), p)
+
+			if !dryRun {
+				err := d.Remove(p)
+				if err != nil {
+					slog.Error("Failed to remove", "path", p, "err", err)
+					failed++
+				}
+			}
+		}
+
+		// NOTE(patrik): The overall progress covers every album we are
+		// going to export
+		for _, item := range items {
+			if item.state == syncUnchanged || dryRun {
+				continue
+			}
+
+			for _, track := range item.config.Tracks {
+				opts.Progress.Plan(time.Duration(track.Duration) * time.Second)
+			}
+		}
+
+		added, updated, unchanged := 0, 0, 0
+		for _, item := range items {
+			switch item.state {
+			case syncUnchanged:
+				unchanged++
+				continue
+			case syncAdded:
+				added++
+				opts.Progress.Printf("%s '%s'\n", verb("Add"), item.name())
+			case syncUpdated:
+				updated++
+				opts.Progress.Printf("%s '%s'\n", verb("Update"), item.name())
+			}
+
+			if dryRun {
+				continue
+			}
+
+			err := album.ExecuteConfig(item.config, opts, item.src, dst)
+			if err != nil {
+				opts.Progress.Printf("%s: %s: %v\n", item.src, item.name(), err)
+				failed++
+			}
+		}
+
+		opts.Progress.Finish()
+
+		fmt.Printf("%d added, %d updated, %d removed, %d unchanged", added, updated, len(removed), unchanged)
+		if failed > 0 {
+			fmt.Printf(", %d failed", failed)
+		}
+		fmt.Println()
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	syncCmd.Flags().BoolP("dry-run", "n", false, "only print the changes")
+	syncCmd.Flags().Bool("force", false, "export the albums that is already up to date")
+	syncCmd.Flags().Bool("prune", true, "remove the outputs without a source")
+	addExportFlags(syncCmd)
+
+	rootCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func prompt(question string) string {
+	fmt.Print(question)
+
+	line, err := stdin.ReadString('\n')
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(line)
+}
+
+func confirm(question string) bool {
+	answer := strings.ToLower(prompt(question + " [y/N]: "))
+	return answer == "y" || answer == "yes"
+}
+
+func readAlbumMetadata(p string) types.AlbumMetadata {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var metadata types.AlbumMetadata
+	err = toml.Unmarshal(data, &metadata)
+	if err != nil {
+		log.Fatalf("%s: %v", p, err)
+	}
+
+	return metadata
+}
+
+func writeAlbumMetadata(p string, metadata types.AlbumMetadata) {
+	data, err := toml.Marshal(metadata)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(p, data, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// findAlbumDirs returns the directories under root containing an
+// album.toml
+func findAlbumDirs(root string) []string {
+	var dirs []string
+
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Error("Failed to walk", "path", p, "err", err)
+			return nil
+		}
+
+		if !d.IsDir() && d.Name() == "album.toml" {
+			dirs = append(dirs, path.Dir(p))
+		}
+
+		return nil
+	})
+
+	return dirs
+}
+
+// findExportDirs returns the directories under root containing an
+// album.toml or a singles.toml, the errors from the walk is returned
+// together with the directories found
+func findExportDirs(root string) ([]string, error) {
+	var dirs []string
+	var errs []error
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// NOTE(patrik): A missing or unreadable root is fatal
+			if p == root {
+				return err
+			}
+
+			errs = append(errs, err)
+			return nil
+		}
+
+		if d.IsDir() || (d.Name() != "album.toml" && d.Name() != "singles.toml") {
+			return nil
+		}
+
+		// NOTE(patrik): Directories with both files is only exported once
+		dir := path.Dir(p)
+		if len(dirs) == 0 || dirs[len(dirs)-1] != dir {
+			dirs = append(dirs, dir)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, errors.Join(errs...)
+}
+
+func getAlbumDirs(cmd *cobra.Command, args []string) []string {
+	recursive, _ := cmd.Flags().GetBool("recursive")
+
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	if !recursive {
+		return args
+	}
+
+	var dirs []string
+	for _, arg := range args {
+		dirs = append(dirs, findAlbumDirs(arg)...)
+	}
+
+	return dirs
+}
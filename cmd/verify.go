@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/nanoteck137/slurpuff/audio"
+	"github.com/nanoteck137/slurpuff/utils"
+	"github.com/spf13/cobra"
+)
+
+// NOTE(patrik): The durations inside album.toml is truncated to seconds
+const maxDurationDiff = 1
+
+// verifyFile checks the file for decode errors and that the duration
+// matches, a duration of 0 skips the duration check
+func verifyFile(p string, duration int) error {
+	_, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	if path.Ext(p) == ".flac" {
+		err := audio.VerifyFlacMD5(p)
+		if errors.Is(err, audio.ErrMD5Unavailable) {
+			err = audio.DecodeCheck(p)
+		}
+
+		if err != nil {
+			return err
+		}
+	} else {
+		err := audio.DecodeCheck(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	if duration == 0 {
+		return nil
+	}
+
+	info, err := utils.GetInfo(p)
+	if err != nil {
+		return err
+	}
+
+	diff := info.Duration - duration
+	if diff < -maxDurationDiff || diff > maxDurationDiff {
+		return fmt.Errorf("duration mismatch: expected %ds got %ds", duration, info.Duration)
+	}
+
+	return nil
+}
+
+func verifyAlbum(dir string) bool {
+	metadata := readAlbumMetadata(path.Join(dir, "album.toml"))
+
+	var failed []string
+	count := 0
+	seen := make(map[string]bool)
+
+	for _, track := range metadata.Tracks {
+		for _, name := range []string{track.File.Lossless, track.File.Lossy} {
+			if name == "" || seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			count++
+
+			// NOTE(patrik): Split tracks is only part of the file
+			duration := track.Duration
+			if name == track.File.Lossless && track.File.IsSplit() {
+				duration = 0
+			}
+
+			err := verifyFile(path.Join(dir, name), duration)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("%s: FAILED (%d of %d files)\n", dir, len(failed), count)
+		for _, f := range failed {
+			fmt.Printf("  %s\n", f)
+		}
+
+		return false
+	}
+
+	fmt.Printf("%s: OK (%d files)\n", dir, count)
+	return true
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [album dirs...]",
+	Short: "Verify the integrity of the album audio files",
+	Run: func(cmd *cobra.Command, args []string) {
+		ok := true
+		for _, dir := range getAlbumDirs(cmd, args) {
+			if !verifyAlbum(dir) {
+				ok = false
+			}
+		}
+
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	verifyCmd.Flags().BoolP("recursive", "r", false, "Recursively search for 'album.toml' to verify")
+
+	rootCmd.AddCommand(verifyCmd)
+}
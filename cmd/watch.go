@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/nanoteck137/slurpuff/utils"
+	"github.com/spf13/cobra"
+)
+
+// isWatchedFile reports if changes to the file should trigger a new
+// export of the album
+func isWatchedFile(name string) bool {
+	if name[0] == '.' {
+		return false
+	}
+
+	switch name {
+	case "album.toml", "singles.toml":
+		return true
+	}
+
+	ext := path.Ext(name)
+	return utils.IsValidTrackExt(ext) || utils.IsValidCoverExt(ext) || strings.EqualFold(ext, ".cue") || strings.EqualFold(ext, ".pdf")
+}
+
+// findExportRoot returns the closest directory from dir up to root with an
+// album.toml or singles.toml, empty if there is none
+func findExportRoot(root, dir string) string {
+	root = filepath.Clean(root)
+
+	for {
+		for _, name := range []string{"album.toml", "singles.toml"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+
+		if dir == root || !strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return ""
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
+
+// findExportRoots returns the export roots affected by the new directory,
+// the directory can be an album, a folder inside an album or a folder with
+// albums inside (a whole artist copied in)
+func findExportRoots(root, dir string) []string {
+	if exportRoot := findExportRoot(root, dir); exportRoot != "" {
+		return []string{exportRoot}
+	}
+
+	var res []string
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if d.IsDir() {
+			if d.Name()[0] == '.' && p != dir {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.Name() == "album.toml" || d.Name() == "singles.toml" {
+			res = append(res, filepath.Dir(p))
+		}
+
+		return nil
+	})
+
+	return res
+}
+
+// addWatches adds dir and all the directories inside to the watcher
+func addWatches(watcher *fsnotify.Watcher, dir string) {
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Error("Failed to watch", "path", p, "err", err)
+			return nil
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if d.Name()[0] == '.' && p != dir {
+			return filepath.SkipDir
+		}
+
+		err = watcher.Add(p)
+		if err != nil {
+			slog.Error("Failed to watch", "path", p, "err", err)
+		}
+
+		return nil
+	})
+}
+
+// debouncer collects the changed albums and sends them when no changes
+// has happened for the delay
+type debouncer struct {
+	delay  time.Duration
+	out    chan string
+	lock   sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func (d *debouncer) trigger(dir string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if timer, exists := d.timers[dir]; exists {
+		timer.Reset(d.delay)
+		return
+	}
+
+	d.timers[dir] = time.AfterFunc(d.delay, func() {
+		d.lock.Lock()
+		delete(d.timers, dir)
+		d.lock.Unlock()
+
+		d.out <- dir
+	})
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <library>",
+	Short: "Export albums again when the files inside them changes",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := filepath.Clean(args[0])
+		dst, _ := cmd.Flags().GetString("dst")
+		delay, _ := cmd.Flags().GetDuration("debounce")
+
+		opts := getExportOptions(cmd)
+
+		d, dst := openDestination(dst)
+		defer d.Close()
+
+		opts.Destination = d
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer watcher.Close()
+
+		addWatches(watcher, root)
+
+		changed := &debouncer{
+			delay:  delay,
+			out:    make(chan string),
+			timers: make(map[string]*time.Timer),
+		}
+
+		// NOTE(patrik): Exports runs one at a time, changes during an
+		// export is queued by the debouncer
+		go func() {
+			for dir := range changed.out {
+				slog.Info("Exporting", "dir", dir)
+
+				// NOTE(patrik): New tracker for every export so the ETA is
+				// only for the current export
+				opts.Progress = newProgress(cmd)
+
+				start := time.Now()
+				err := exportDir(opts, dir, dst)
+				opts.Progress.Finish()
+				if err != nil {
+					slog.Error("Export failed", "dir", dir, "err", err)
+					continue
+				}
+
+				slog.Info("Exported", "dir", dir, "took", time.Since(start).Round(time.Millisecond))
+			}
+		}()
+
+		slog.Info("Watching", "library", root, "mode", opts.Mode, "dst", dst)
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Has(fsnotify.Create) {
+					info, err := os.Stat(event.Name)
+					if err == nil && info.IsDir() {
+						addWatches(watcher, event.Name)
+
+						// NOTE(patrik): Albums copied or moved in as a whole
+						// folder has their files in place before the watch
+						// is added, no events is sent for them
+						for _, dir := range findExportRoots(root, event.Name) {
+							changed.trigger(dir)
+						}
+
+						continue
+					}
+				}
+
+				if event.Has(fsnotify.Chmod) || !isWatchedFile(filepath.Base(event.Name)) {
+					continue
+				}
+
+				dir := findExportRoot(root, filepath.Dir(event.Name))
+				if dir == "" {
+					continue
+				}
+
+				changed.trigger(dir)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.Error("Watcher error", "err", err)
+			}
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().String("dst", "", "output directory or sftp://[user@]host[:port]/path")
+	watchCmd.Flags().Duration("debounce", 2*time.Second, "time to wait for more changes before exporting")
+	addExportFlags(watchCmd)
+
+	watchCmd.MarkFlagRequired("dst")
+
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindExportRoots(t *testing.T) {
+	root := t.TempDir()
+
+	files := []string{
+		"Artist/Album/album.toml",
+		"Artist/Album/CD1/01.flac",
+		"Artist/Other/album.toml",
+		"Artist/.hidden/album.toml",
+		"Singles/singles.toml",
+		"Loose/01.flac",
+	}
+
+	for _, name := range files {
+		p := filepath.Join(root, name)
+
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(p, nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir   string
+		roots []string
+	}{
+		{"Artist/Album", []string{"Artist/Album"}},
+		{"Artist/Album/CD1", []string{"Artist/Album"}},
+		{"Artist", []string{"Artist/Album", "Artist/Other"}},
+		{"Singles", []string{"Singles"}},
+		{"Loose", nil},
+	}
+
+	for _, test := range tests {
+		var expected []string
+		for _, dir := range test.roots {
+			expected = append(expected, filepath.Join(root, dir))
+		}
+
+		roots := findExportRoots(root, filepath.Join(root, test.dir))
+		if !reflect.DeepEqual(roots, expected) {
+			t.Errorf("%s: got %v, expected %v", test.dir, roots, expected)
+		}
+	}
+}
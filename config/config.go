@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+type CoverConfig struct {
+	MaxSize      int   `toml:"max_size"`
+	EmbedMaxSize int   `toml:"embed_max_size"`
+	Quality      int   `toml:"quality"`
+	Thumbnails   []int `toml:"thumbnails"`
+}
+
+type ModeConfig struct {
+	Template      string `toml:"template"`
+	Sanitize      string `toml:"sanitize"`
+	MaxPathLength int    `toml:"max_path_length"`
+
+	AllowLossyTranscode bool   `toml:"allow_lossy_transcode"`
+	Resampler           string `toml:"resampler"`
+	MaxSampleRate       int    `toml:"max_sample_rate"`
+	MaxBitDepth         int    `toml:"max_bit_depth"`
+
+	Cover        CoverConfig `toml:"cover"`
+	ArtworkTypes []string    `toml:"artwork_types"`
+}
+
+type Config struct {
+	Modes map[string]ModeConfig `toml:"modes"`
+}
+
+func (c *Config) Mode(mode string) ModeConfig {
+	return c.Modes[mode]
+}
+
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return path.Join(dir, "slurpuff", "config.toml")
+}
+
+// Load reads the config file at p, a missing file is not an error if p is
+// the default path
+func Load(p string) (Config, error) {
+	isDefault := p == ""
+	if isDefault {
+		p = DefaultPath()
+		if p == "" {
+			return Config{}, nil
+		}
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if isDefault && errors.Is(err, fs.ErrNotExist) {
+			return Config{}, nil
+		}
+
+		return Config{}, err
+	}
+
+	var config Config
+	err = toml.Unmarshal(data, &config)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", p, err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,317 @@
+package cover
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/nanoteck137/slurpuff/utils"
+)
+
+const (
+	DefaultEmbedMaxSize = 1000
+	DefaultQuality      = 90
+)
+
+type Options struct {
+	// Maximum width and height of the full size copy, 0 keeps the
+	// original image (only the metadata is removed)
+	MaxSize int
+
+	// Maximum width and height of the copy embedded inside the tracks,
+	// uses DefaultEmbedMaxSize if 0
+	EmbedMaxSize int
+
+	// JPEG quality of the resized images, uses DefaultQuality if 0
+	Quality int
+
+	// Sizes of the thumbnails written next to the cover as
+	// cover-<size>.jpg
+	Thumbnails []int
+}
+
+func (o Options) embedMaxSize() int {
+	if o.EmbedMaxSize == 0 {
+		return DefaultEmbedMaxSize
+	}
+
+	return o.EmbedMaxSize
+}
+
+func (o Options) quality() int {
+	if o.Quality == 0 {
+		return DefaultQuality
+	}
+
+	return o.Quality
+}
+
+// NOTE(patrik): Formats without a Go decoder (avif) is decoded with ffmpeg
+func decodeWithFFmpeg(p string) (image.Image, error) {
+	cmd := exec.Command("ffmpeg", "-v", "error", "-i", p, "-frames:v", "1", "-c:v", "png", "-f", "image2pipe", "-")
+
+	stderr := utils.CaptureStderr(cmd)
+
+	data, err := cmd.Output()
+	err = stderr.Done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(data))
+}
+
+// decode returns the image with the EXIF orientation applied and the
+// orientation of the file
+func decode(p string) (image.Image, int, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		img, err = decodeWithFFmpeg(p)
+	}
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", p, err)
+	}
+
+	// NOTE(patrik): The Go decoders ignores the orientation, covers shot
+	// with a phone is stored sideways and only displayed upright because
+	// of the EXIF data we remove
+	orientation := Orientation(data)
+
+	return applyOrientation(img, orientation), orientation, nil
+}
+
+// Decode decodes the image, the EXIF orientation is applied so the image
+// is upright
+func Decode(p string) (image.Image, error) {
+	img, _, err := decode(p)
+	return img, err
+}
+
+// Resize scales the image down so the width and height fits inside
+// maxSize, smaller images is returned as is
+func Resize(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if maxSize <= 0 || (w <= maxSize && h <= maxSize) {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*maxSize/w)
+		w = maxSize
+	} else {
+		w = max(1, w*maxSize/h)
+		h = maxSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+	return dst
+}
+
+// WriteJPEG encodes the image as JPEG, transparent parts is drawn on top of
+// white
+func WriteJPEG(p string, img image.Image, quality int) error {
+	bounds := img.Bounds()
+
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	err = jpeg.Encode(w, flat, &jpeg.Options{Quality: quality})
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// CopyStripped copies the image without the metadata
+func CopyStripped(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	data, err = StripMetadata(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+
+	return os.WriteFile(dst, data, 0644)
+}
+
+type Result struct {
+	// Full size copy of the cover
+	Cover string
+
+	// Copy of the cover for embedding inside the tracks, the caller is
+	// responsible for removing it
+	Embed string
+
+	Thumbnails []string
+}
+
+// Process writes the full size copy and the thumbnails to dir, the name of
+// the full size copy is cover.<ext>. If dir is empty only the embedded
+// copy is created.
+func Process(src, dir string, opts Options) (Result, error) {
+	img, orientation, err := decode(src)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var res Result
+
+	if dir != "" {
+		res.Cover, err = writeCopy(src, img, orientation, dir, "cover", opts)
+		if err != nil {
+			return Result{}, err
+		}
+
+		for _, size := range opts.Thumbnails {
+			p := path.Join(dir, "cover-"+strconv.Itoa(size)+".jpg")
+			err := WriteJPEG(p, Resize(img, size), opts.quality())
+			if err != nil {
+				return Result{}, err
+			}
+
+			res.Thumbnails = append(res.Thumbnails, p)
+		}
+	}
+
+	res.Embed, err = writeEmbedCopy(img, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return res, nil
+}
+
+func writeEmbedCopy(img image.Image, opts Options) (string, error) {
+	f, err := os.CreateTemp("", "slurpuff-cover-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	err = WriteJPEG(f.Name(), Resize(img, opts.embedMaxSize()), opts.quality())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// EmbedCopy creates a temporary copy of the image for embedding inside
+// the tracks, the caller is responsible for removing it
+func EmbedCopy(src string, opts Options) (string, error) {
+	img, err := Decode(src)
+	if err != nil {
+		return "", err
+	}
+
+	return writeEmbedCopy(img, opts)
+}
+
+// NOTE(patrik): Formats most players and containers can read, the other
+// formats is converted to JPEG if lossy (webp, avif) or PNG
+func convertedExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg", ".png":
+		return ext
+	case ".webp", ".avif":
+		return ".jpg"
+	}
+
+	return ".png"
+}
+
+func writeCopy(src string, img image.Image, orientation int, dir, name string, opts Options) (string, error) {
+	if opts.MaxSize > 0 && needsResize(img, opts.MaxSize) {
+		p := path.Join(dir, name+".jpg")
+		return p, WriteJPEG(p, Resize(img, opts.MaxSize), opts.quality())
+	}
+
+	srcExt := path.Ext(src)
+	ext := convertedExt(srcExt)
+	p := path.Join(dir, name+ext)
+
+	// NOTE(patrik): The orientation is removed together with the rest of
+	// the metadata, rotated images needs to be encoded again
+	switch {
+	case ext == srcExt && orientation <= 1:
+		return p, CopyStripped(src, p)
+	case strings.EqualFold(ext, ".jpg") || strings.EqualFold(ext, ".jpeg"):
+		return p, WriteJPEG(p, img, opts.quality())
+	default:
+		return p, WritePNG(p, img)
+	}
+}
+
+// WriteCopy writes a copy of the image to dir/name.<ext> converted to a
+// format players can read, resized if larger than opts.MaxSize and
+// without the metadata
+func WriteCopy(src, dir, name string, opts Options) (string, error) {
+	img, orientation, err := decode(src)
+	if err != nil {
+		return "", err
+	}
+
+	return writeCopy(src, img, orientation, dir, name, opts)
+}
+
+func WritePNG(p string, img image.Image) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	err = png.Encode(w, img)
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func needsResize(img image.Image, maxSize int) bool {
+	bounds := img.Bounds()
+	return bounds.Dx() > maxSize || bounds.Dy() > maxSize
+}
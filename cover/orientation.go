@@ -0,0 +1,206 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const exifOrientationTag = 0x0112
+
+// Orientation returns the EXIF orientation (1-8) of the JPEG, PNG or WebP
+// image, 1 if the image has no orientation
+func Orientation(data []byte) int {
+	var exif []byte
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		exif = jpegExif(data)
+	case bytes.HasPrefix(data, pngSignature):
+		exif = pngExif(data)
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		exif = webpExif(data)
+	}
+
+	orientation := exifOrientation(exif)
+	if orientation < 1 || orientation > 8 {
+		return 1
+	}
+
+	return orientation
+}
+
+// jpegExif returns the TIFF data of the EXIF APP1 segment
+func jpegExif(data []byte) []byte {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return nil
+		}
+
+		marker := data[pos+1]
+		if marker == 0xd8 || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) || marker == 0xff {
+			pos++
+			continue
+		}
+
+		// NOTE(patrik): The EXIF segment is always before the image data
+		if marker == 0xda || marker == 0xd9 {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+
+		pos += 2 + length
+	}
+
+	return nil
+}
+
+// pngExif returns the data of the eXIf chunk
+func pngExif(data []byte) []byte {
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+
+		end := pos + 8 + length + 4
+		if length < 0 || end > len(data) {
+			return nil
+		}
+
+		if typ == "eXIf" {
+			return data[pos+8 : pos+8+length]
+		}
+
+		if typ == "IDAT" || typ == "IEND" {
+			return nil
+		}
+
+		pos = end
+	}
+
+	return nil
+}
+
+// webpExif returns the data of the EXIF chunk
+func webpExif(data []byte) []byte {
+	pos := 12
+	for pos+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if length < 0 || pos+8+length > len(data) {
+			return nil
+		}
+
+		if string(data[pos:pos+4]) == "EXIF" {
+			// NOTE(patrik): Some encoders keeps the JPEG "Exif" header
+			return bytes.TrimPrefix(data[pos+8:pos+8+length], []byte("Exif\x00\x00"))
+		}
+
+		// NOTE(patrik): Chunks is padded to an even size
+		pos += 8 + length + length&1
+	}
+
+	return nil
+}
+
+// exifOrientation reads the orientation tag from the first IFD of the
+// TIFF data, 0 if missing
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[0:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			// NOTE(patrik): The value is a SHORT stored at the start of
+			// the value field
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 0
+}
+
+// applyOrientation returns the image transformed so it is displayed
+// upright without the orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// NOTE(patrik): Orientation 5-8 is rotated 90 degrees so the width and
+	// height is swapped
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+
+			switch orientation {
+			case 2:
+				// Mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3:
+				// Rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				// Mirrored vertically
+				sx, sy = x, h-1-y
+			case 5:
+				// Transposed
+				sx, sy = y, x
+			case 6:
+				// Rotated 90 clockwise
+				sx, sy = y, h-1-x
+			case 7:
+				// Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				// Rotated 90 counter clockwise
+				sx, sy = w-1-y, x
+			}
+
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,194 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+)
+
+// exifTIFF returns little endian TIFF data with only the orientation tag
+func exifTIFF(orientation int) []byte {
+	data := []byte("II*\x00")
+	data = binary.LittleEndian.AppendUint32(data, 8)
+	data = binary.LittleEndian.AppendUint16(data, 1)
+
+	// Tag, type (SHORT), count and value
+	data = binary.LittleEndian.AppendUint16(data, exifOrientationTag)
+	data = binary.LittleEndian.AppendUint16(data, 3)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint16(data, uint16(orientation))
+	data = binary.LittleEndian.AppendUint16(data, 0)
+
+	return binary.LittleEndian.AppendUint32(data, 0)
+}
+
+func withJPEGExif(data []byte, orientation int) []byte {
+	exif := append([]byte("Exif\x00\x00"), exifTIFF(orientation)...)
+
+	res := []byte{0xff, 0xd8, 0xff, 0xe1}
+	res = binary.BigEndian.AppendUint16(res, uint16(len(exif)+2))
+	res = append(res, exif...)
+
+	return append(res, data[2:]...)
+}
+
+func withPNGExif(data []byte, orientation int) []byte {
+	exif := exifTIFF(orientation)
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(exif)))
+	chunk = append(chunk, "eXIf"...)
+	chunk = append(chunk, exif...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	// NOTE(patrik): Signature and the IHDR chunk
+	ihdr := len(pngSignature) + 8 + 13 + 4
+
+	res := append([]byte{}, data[:ihdr]...)
+	res = append(res, chunk...)
+
+	return append(res, data[ihdr:]...)
+}
+
+// testImage returns a 3x2 image where every pixel has a different color
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 100), G: uint8(y * 100), A: 255})
+		}
+	}
+
+	return img
+}
+
+func TestOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o := Orientation(buf.Bytes()); o != 1 {
+		t.Errorf("png without exif: got %d, expected 1", o)
+	}
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		if o := Orientation(withPNGExif(buf.Bytes(), orientation)); o != orientation {
+			t.Errorf("png: got %d, expected %d", o, orientation)
+		}
+
+		if o := Orientation(withJPEGExif([]byte{0xff, 0xd8, 0xff, 0xd9}, orientation)); o != orientation {
+			t.Errorf("jpeg: got %d, expected %d", o, orientation)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	src := testImage()
+
+	// NOTE(patrik): The source pixel expected at the top left and top right
+	// corners of the upright image
+	tests := []struct {
+		orientation int
+		w, h        int
+		topLeft     image.Point
+		topRight    image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0), image.Pt(2, 0)},
+		{2, 3, 2, image.Pt(2, 0), image.Pt(0, 0)},
+		{3, 3, 2, image.Pt(2, 1), image.Pt(0, 1)},
+		{4, 3, 2, image.Pt(0, 1), image.Pt(2, 1)},
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 1)},
+		{6, 2, 3, image.Pt(0, 1), image.Pt(0, 0)},
+		{7, 2, 3, image.Pt(2, 1), image.Pt(2, 0)},
+		{8, 2, 3, image.Pt(2, 0), image.Pt(2, 1)},
+	}
+
+	for _, test := range tests {
+		img := applyOrientation(src, test.orientation)
+
+		bounds := img.Bounds()
+		if bounds.Dx() != test.w || bounds.Dy() != test.h {
+			t.Errorf("orientation %d: got %dx%d, expected %dx%d", test.orientation, bounds.Dx(), bounds.Dy(), test.w, test.h)
+			continue
+		}
+
+		if img.At(0, 0) != src.At(test.topLeft.X, test.topLeft.Y) {
+			t.Errorf("orientation %d: wrong top left pixel", test.orientation)
+		}
+
+		if img.At(test.w-1, 0) != src.At(test.topRight.X, test.topRight.Y) {
+			t.Errorf("orientation %d: wrong top right pixel", test.orientation)
+		}
+	}
+}
+
+func TestProcessRotates(t *testing.T) {
+	dir := t.TempDir()
+
+	var pngBuf bytes.Buffer
+	err := png.Encode(&pngBuf, testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jpegBuf bytes.Buffer
+	err = jpeg.Encode(&jpegBuf, testImage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"src.png", withPNGExif(pngBuf.Bytes(), 6)},
+		{"src.jpg", withJPEGExif(jpegBuf.Bytes(), 6)},
+	}
+
+	for _, test := range tests {
+		src := path.Join(dir, test.name)
+		err := os.WriteFile(src, test.data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := path.Join(dir, "out-"+path.Ext(test.name)[1:])
+		err = os.Mkdir(out, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := Process(src, out, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(res.Embed)
+
+		data, err := os.ReadFile(res.Cover)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if o := Orientation(data); o != 1 {
+			t.Errorf("%s: cover still has orientation %d", test.name, o)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bounds := img.Bounds()
+		if bounds.Dx() != 2 || bounds.Dy() != 3 {
+			t.Errorf("%s: got %dx%d, expected 2x3", test.name, bounds.Dx(), bounds.Dy())
+		}
+	}
+}
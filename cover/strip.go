@@ -0,0 +1,138 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownFormat = errors.New("unknown image format")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// StripMetadata removes EXIF, XMP and comments from the JPEG or PNG image
+// without touching the image data
+func StripMetadata(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return stripJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNG(data)
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// NOTE(patrik): APP0 (JFIF) and APP2 (ICC profile) is kept, the rest of
+// the metadata segments can contain camera and location data
+func keepJPEGSegment(marker byte) bool {
+	switch marker {
+	case 0xe1, 0xed, 0xfe:
+		// APP1 (EXIF, XMP), APP13 (Photoshop, IPTC) and COM
+		return false
+	}
+
+	return true
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	res := []byte{0xff, 0xd8}
+
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xff {
+			return nil, fmt.Errorf("jpeg: expected marker at %d", pos)
+		}
+
+		// NOTE(patrik): Markers can be padded with any number of 0xff
+		for pos < len(data) && data[pos] == 0xff {
+			pos++
+		}
+
+		if pos >= len(data) {
+			return nil, fmt.Errorf("jpeg: truncated")
+		}
+
+		marker := data[pos]
+		pos++
+
+		// Markers without a length
+		if marker == 0xd8 || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			res = append(res, 0xff, marker)
+			continue
+		}
+
+		if marker == 0xd9 {
+			res = append(res, 0xff, marker)
+			return res, nil
+		}
+
+		if pos+2 > len(data) {
+			return nil, fmt.Errorf("jpeg: truncated")
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, fmt.Errorf("jpeg: invalid segment length")
+		}
+
+		// NOTE(patrik): Start of scan, the rest is the compressed image
+		// data
+		if marker == 0xda {
+			res = append(res, 0xff, marker)
+			res = append(res, data[pos:]...)
+			return res, nil
+		}
+
+		if keepJPEGSegment(marker) {
+			res = append(res, 0xff, marker)
+			res = append(res, data[pos:pos+length]...)
+		}
+
+		pos += length
+	}
+
+	return res, nil
+}
+
+func keepPNGChunk(typ string) bool {
+	switch typ {
+	case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		return false
+	}
+
+	return true
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	res := append([]byte{}, pngSignature...)
+
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("png: truncated")
+		}
+
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+
+		// Length, type, data and crc
+		end := pos + 8 + length + 4
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("png: invalid chunk length")
+		}
+
+		if keepPNGChunk(typ) {
+			res = append(res, data[pos:end]...)
+		}
+
+		pos = end
+
+		if typ == "IEND" {
+			break
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,280 @@
+package cue
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// NOTE(patrik): Times inside cue sheets is MM:SS:FF where FF is CD frames
+// (1/75 second)
+const FramesPerSecond = 75
+
+type Index struct {
+	Number int
+	Frames int64
+}
+
+type Track struct {
+	Number     int
+	Title      string
+	Performer  string
+	Songwriter string
+	ISRC       string
+	Indexes    []Index
+}
+
+// Start returns the position of INDEX 01 in frames, the start of the track
+// after the pregap
+func (t *Track) Start() (int64, bool) {
+	for _, index := range t.Indexes {
+		if index.Number == 1 {
+			return index.Frames, true
+		}
+	}
+
+	return 0, false
+}
+
+type File struct {
+	Name   string
+	Type   string
+	Tracks []Track
+}
+
+type Sheet struct {
+	Title     string
+	Performer string
+	Date      string
+	Genre     string
+	Catalog   string
+	Disc      int
+
+	Files []File
+}
+
+// Year returns the year from REM DATE, 0 if missing
+func (s *Sheet) Year() int {
+	if len(s.Date) < 4 {
+		return 0
+	}
+
+	year, err := strconv.Atoi(s.Date[:4])
+	if err != nil {
+		return 0
+	}
+
+	return year
+}
+
+// FramesToSamples converts a position in frames to samples
+func FramesToSamples(frames int64, sampleRate int) int64 {
+	return frames * int64(sampleRate) / FramesPerSecond
+}
+
+// parseTime parses MM:SS:FF to frames
+func parseTime(s string) (int64, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	var values [3]int64
+	for i, part := range parts {
+		v, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid time: %s", s)
+		}
+
+		values[i] = v
+	}
+
+	if values[1] >= 60 || values[2] >= FramesPerSecond {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	return (values[0]*60+values[1])*FramesPerSecond + values[2], nil
+}
+
+// splitLine splits the line into fields, quoted fields can contain spaces
+func splitLine(line string) []string {
+	var fields []string
+
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return fields
+		}
+
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end == -1 {
+				fields = append(fields, line[1:])
+				return fields
+			}
+
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+
+		end := strings.IndexAny(line, " \t")
+		if end == -1 {
+			fields = append(fields, line)
+			return fields
+		}
+
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+}
+
+// decode returns the sheet as UTF-8, sheets from older rippers is usually
+// Windows-1252
+func decode(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+
+	res, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(res), nil
+}
+
+func Parse(data []byte) (Sheet, error) {
+	content, err := decode(data)
+	if err != nil {
+		return Sheet{}, err
+	}
+
+	var sheet Sheet
+	var file *File
+	var track *Track
+
+	for n, line := range strings.Split(content, "\n") {
+		fields := splitLine(strings.TrimRight(line, "\r"))
+		if len(fields) == 0 {
+			continue
+		}
+
+		lineErr := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: %s", n+1, fmt.Sprintf(format, args...))
+		}
+
+		arg := func(i int) string {
+			if i < len(fields) {
+				return fields[i]
+			}
+
+			return ""
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "REM":
+			switch strings.ToUpper(arg(1)) {
+			case "DATE":
+				sheet.Date = arg(2)
+			case "GENRE":
+				sheet.Genre = strings.Join(fields[2:], " ")
+			case "DISCNUMBER":
+				sheet.Disc, _ = strconv.Atoi(arg(2))
+			}
+		case "CATALOG":
+			sheet.Catalog = arg(1)
+		case "TITLE":
+			if track != nil {
+				track.Title = arg(1)
+			} else {
+				sheet.Title = arg(1)
+			}
+		case "PERFORMER":
+			if track != nil {
+				track.Performer = arg(1)
+			} else {
+				sheet.Performer = arg(1)
+			}
+		case "SONGWRITER":
+			if track != nil {
+				track.Songwriter = arg(1)
+			}
+		case "ISRC":
+			if track != nil {
+				track.ISRC = arg(1)
+			}
+		case "FILE":
+			sheet.Files = append(sheet.Files, File{
+				Name: arg(1),
+				Type: arg(2),
+			})
+			file = &sheet.Files[len(sheet.Files)-1]
+			track = nil
+		case "TRACK":
+			if file == nil {
+				return Sheet{}, lineErr("TRACK before FILE")
+			}
+
+			num, err := strconv.Atoi(arg(1))
+			if err != nil {
+				return Sheet{}, lineErr("invalid track number: %s", arg(1))
+			}
+
+			file.Tracks = append(file.Tracks, Track{
+				Number: num,
+			})
+			track = &file.Tracks[len(file.Tracks)-1]
+		case "INDEX":
+			if track == nil {
+				return Sheet{}, lineErr("INDEX before TRACK")
+			}
+
+			num, err := strconv.Atoi(arg(1))
+			if err != nil {
+				return Sheet{}, lineErr("invalid index number: %s", arg(1))
+			}
+
+			frames, err := parseTime(arg(2))
+			if err != nil {
+				return Sheet{}, lineErr("%v", err)
+			}
+
+			track.Indexes = append(track.Indexes, Index{
+				Number: num,
+				Frames: frames,
+			})
+		}
+	}
+
+	for _, file := range sheet.Files {
+		for _, track := range file.Tracks {
+			if _, ok := track.Start(); !ok {
+				return Sheet{}, fmt.Errorf("track %d has no INDEX 01", track.Number)
+			}
+		}
+	}
+
+	return sheet, nil
+}
+
+func ParseFile(p string) (Sheet, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return Sheet{}, err
+	}
+
+	sheet, err := Parse(data)
+	if err != nil {
+		return Sheet{}, fmt.Errorf("%s: %w", p, err)
+	}
+
+	return sheet, nil
+}
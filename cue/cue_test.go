@@ -0,0 +1,224 @@
+package cue
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		sheet Sheet
+		err   string
+	}{
+		{
+			name: "quoted titles",
+			data: "REM GENRE \"Progressive Rock\"\r\n" +
+				"REM DATE 1973\r\n" +
+				"PERFORMER \"Some Artist\"\r\n" +
+				"TITLE \"The Album: Part One\"\r\n" +
+				"FILE \"The Album.wav\" WAVE\r\n" +
+				"  TRACK 01 AUDIO\r\n" +
+				"    TITLE \"Speak to Me\"\r\n" +
+				"    ISRC GBAYE7300001\r\n" +
+				"    INDEX 01 00:00:00\r\n" +
+				"  TRACK 02 AUDIO\r\n" +
+				"    TITLE \"Breathe (In the Air)\"\r\n" +
+				"    PERFORMER \"Other Artist\"\r\n" +
+				"    INDEX 01 01:30:74\r\n",
+			sheet: Sheet{
+				Title:     "The Album: Part One",
+				Performer: "Some Artist",
+				Date:      "1973",
+				Genre:     "Progressive Rock",
+				Files: []File{
+					{
+						Name: "The Album.wav",
+						Type: "WAVE",
+						Tracks: []Track{
+							{
+								Number:  1,
+								Title:   "Speak to Me",
+								ISRC:    "GBAYE7300001",
+								Indexes: []Index{{Number: 1, Frames: 0}},
+							},
+							{
+								Number:    2,
+								Title:     "Breathe (In the Air)",
+								Performer: "Other Artist",
+								Indexes:   []Index{{Number: 1, Frames: 90*75 + 74}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "windows-1252",
+			data: "PERFORMER \"Bj\xf6rk\"\n" +
+				"TITLE \"D\xe9but\"\n" +
+				"FILE \"D\xe9but.flac\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"TITLE \"Caf\xe9 \x93Noir\x94\"\n" +
+				"INDEX 01 00:00:00\n",
+			sheet: Sheet{
+				Title:     "Début",
+				Performer: "Björk",
+				Files: []File{
+					{
+						Name: "Début.flac",
+						Type: "WAVE",
+						Tracks: []Track{
+							{
+								Number:  1,
+								Title:   "Café “Noir”",
+								Indexes: []Index{{Number: 1, Frames: 0}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "pregaps",
+			data: "FILE \"a.wav\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"INDEX 01 00:00:00\n" +
+				"TRACK 02 AUDIO\n" +
+				"INDEX 00 03:10:00\n" +
+				"INDEX 01 03:12:15\n",
+			sheet: Sheet{
+				Files: []File{
+					{
+						Name: "a.wav",
+						Type: "WAVE",
+						Tracks: []Track{
+							{
+								Number:  1,
+								Indexes: []Index{{Number: 1, Frames: 0}},
+							},
+							{
+								Number: 2,
+								Indexes: []Index{
+									{Number: 0, Frames: 190 * 75},
+									{Number: 1, Frames: 192*75 + 15},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "multiple files",
+			data: "FILE \"01.wav\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"TITLE \"One\"\n" +
+				"INDEX 01 00:00:00\n" +
+				"FILE \"02.wav\" WAVE\n" +
+				"TRACK 02 AUDIO\n" +
+				"TITLE \"Two\"\n" +
+				"INDEX 01 00:00:00\n" +
+				"TRACK 03 AUDIO\n" +
+				"TITLE \"Three\"\n" +
+				"INDEX 01 02:00:00\n",
+			sheet: Sheet{
+				Files: []File{
+					{
+						Name: "01.wav",
+						Type: "WAVE",
+						Tracks: []Track{
+							{
+								Number:  1,
+								Title:   "One",
+								Indexes: []Index{{Number: 1, Frames: 0}},
+							},
+						},
+					},
+					{
+						Name: "02.wav",
+						Type: "WAVE",
+						Tracks: []Track{
+							{
+								Number:  2,
+								Title:   "Two",
+								Indexes: []Index{{Number: 1, Frames: 0}},
+							},
+							{
+								Number:  3,
+								Title:   "Three",
+								Indexes: []Index{{Number: 1, Frames: 120 * 75}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "missing index 01",
+			data: "FILE \"a.wav\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"INDEX 01 00:00:00\n" +
+				"TRACK 02 AUDIO\n" +
+				"INDEX 00 03:10:00\n",
+			err: "track 2 has no INDEX 01",
+		},
+		{
+			name: "track before file",
+			data: "TRACK 01 AUDIO\n",
+			err:  "line 1: TRACK before FILE",
+		},
+		{
+			name: "invalid time",
+			data: "FILE \"a.wav\" WAVE\n" +
+				"TRACK 01 AUDIO\n" +
+				"INDEX 01 00:00:75\n",
+			err: "line 3: invalid time: 00:00:75",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sheet, err := Parse([]byte(test.data))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(sheet, test.sheet) {
+				t.Errorf("got %+v, expected %+v", sheet, test.sheet)
+			}
+		})
+	}
+}
+
+func TestFramesToSamples(t *testing.T) {
+	tests := []struct {
+		frames     int64
+		sampleRate int
+		samples    int64
+	}{
+		{0, 44100, 0},
+		{75, 44100, 44100},
+		{1, 44100, 588},
+		{192*75 + 15, 44100, 192*44100 + 15*588},
+		{1, 48000, 640},
+		{1, 96000, 1280},
+	}
+
+	for _, test := range tests {
+		samples := FramesToSamples(test.frames, test.sampleRate)
+		if samples != test.samples {
+			t.Errorf("FramesToSamples(%d, %d) = %d, expected %d", test.frames, test.sampleRate, samples, test.samples)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package destination
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TempPrefix is the prefix of the files being written, the files is
+// renamed when complete
+const TempPrefix = ".slurpuff-tmp-"
+
+// Destination is the filesystem the exports is written to, all the paths
+// is slash separated paths inside the destination
+type Destination interface {
+	MkdirAll(p string) error
+
+	// WriteFile writes the content of r to p, readers of p only sees the
+	// old or the complete new file
+	WriteFile(p string, r io.Reader) error
+
+	Open(p string) (io.ReadCloser, error)
+	Stat(p string) (fs.FileInfo, error)
+
+	// Remove removes p and everything inside it, removing a path that
+	// doesn't exist is not an error
+	Remove(p string) error
+
+	ReadDir(p string) ([]fs.FileInfo, error)
+
+	// Stage returns a local file the caller can produce p inside, the file
+	// is moved into place by Staged.Commit
+	Stage(p string) (*Staged, error)
+
+	Close() error
+}
+
+type Staged struct {
+	// Local path the file should be written to
+	Path string
+
+	commit  func() error
+	discard func()
+	done    bool
+}
+
+// Commit moves the staged file into place
+func (s *Staged) Commit() error {
+	if s.commit != nil {
+		err := s.commit()
+		if err != nil {
+			return err
+		}
+	}
+
+	s.done = true
+	return nil
+}
+
+// Discard removes the staged file if it was never committed, safe to call
+// after Commit
+func (s *Staged) Discard() {
+	if !s.done {
+		if s.discard != nil {
+			s.discard()
+		} else {
+			os.Remove(s.Path)
+		}
+
+		s.done = true
+	}
+}
+
+// Open returns the destination for dst and the path inside it. dst is
+// either a local path or a sftp://[user@]host[:port]/path URL.
+func Open(dst string) (Destination, string, error) {
+	if !strings.HasPrefix(dst, "sftp://") {
+		return Local{}, path.Clean(dst), nil
+	}
+
+	u, err := url.Parse(dst)
+	if err != nil {
+		return nil, "", err
+	}
+
+	d, err := DialSFTP(u)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", u.Host, err)
+	}
+
+	p := path.Clean(u.Path)
+	if p == "." {
+		p = "/"
+	}
+
+	return d, p, nil
+}
+
+// WriteFileIfChanged only writes the file if the content differs from the
+// file inside the destination
+func WriteFileIfChanged(d Destination, p string, data []byte) error {
+	f, err := d.Open(p)
+	if err == nil {
+		current, err := io.ReadAll(f)
+		f.Close()
+
+		if err == nil && bytes.Equal(current, data) {
+			return nil
+		}
+	}
+
+	return d.WriteFile(p, bytes.NewReader(data))
+}
+
+// Put writes the local file to p
+func Put(d Destination, local, p string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.WriteFile(p, f)
+}
+
+// PutDir writes all the files inside the local directory to dir
+func PutDir(d Destination, local, dir string) error {
+	entries, err := os.ReadDir(local)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		src := path.Join(local, entry.Name())
+		dst := path.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			err := d.MkdirAll(dst)
+			if err == nil {
+				err = PutDir(d, src, dst)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := Put(d, src, dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveTempFiles removes the temporary files left inside dir by
+// interrupted writes, the files of exports still running is kept
+func RemoveTempFiles(d Destination, dir string) error {
+	entries, err := d.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), TempPrefix) {
+			continue
+		}
+
+		if !isStaleTemp(entry) {
+			continue
+		}
+
+		err := d.Remove(path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type WalkFunc func(p string, info fs.FileInfo, err error) error
+
+// Walk works like filepath.Walk but for the destination, the entries is
+// walked in lexical order
+func Walk(d Destination, root string, fn WalkFunc) error {
+	info, err := d.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(d, root, info, fn)
+	}
+
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+
+	return err
+}
+
+func walk(d Destination, p string, info fs.FileInfo, fn WalkFunc) error {
+	if !info.IsDir() {
+		return fn(p, info, nil)
+	}
+
+	entries, err := d.ReadDir(p)
+	err1 := fn(p, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, entry := range entries {
+		err := walk(d, path.Join(p, entry.Name()), entry, fn)
+		if err != nil {
+			if !entry.IsDir() || !errors.Is(err, fs.SkipDir) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package destination
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// NOTE(patrik): The tests is shared between the destinations, dir is an
+// empty directory inside the destination
+
+func readFile(t *testing.T, d Destination, p string) string {
+	t.Helper()
+
+	f, err := d.Open(p)
+	if err != nil {
+		t.Fatalf("open %s: %v", p, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read %s: %v", p, err)
+	}
+
+	return string(data)
+}
+
+func readDirNames(t *testing.T, d Destination, dir string) []string {
+	t.Helper()
+
+	entries, err := d.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir %s: %v", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func testWriteFile(t *testing.T, d Destination, dir string) {
+	p := path.Join(dir, "01 - Track.opus")
+
+	for _, content := range []string{"first", "second"} {
+		err := d.WriteFile(p, strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("write %q: %v", content, err)
+		}
+
+		got := readFile(t, d, p)
+		if got != content {
+			t.Fatalf("got %q, expected %q", got, content)
+		}
+	}
+
+	names := readDirNames(t, d, dir)
+	if len(names) != 1 || names[0] != "01 - Track.opus" {
+		t.Fatalf("expected only the written file, got %v", names)
+	}
+}
+
+func testRemove(t *testing.T, d Destination, dir string) {
+	err := d.Remove(path.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("removing a missing path: %v", err)
+	}
+
+	sub := path.Join(dir, "Artist", "Album")
+	err = d.MkdirAll(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.WriteFile(path.Join(sub, "cover.jpg"), strings.NewReader("cover"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Remove(path.Join(dir, "Artist"))
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	_, err = d.Stat(path.Join(dir, "Artist"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected the directory to be removed, got %v", err)
+	}
+}
+
+func testReadDir(t *testing.T, d Destination, dir string) {
+	err := d.MkdirAll(path.Join(dir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.flac", "c.toml"} {
+		err := d.WriteFile(path.Join(dir, name), strings.NewReader(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := d.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, entry := range entries {
+		got[entry.Name()] = entry.IsDir()
+	}
+
+	expected := map[string]bool{"a.flac": false, "b": true, "c.toml": false}
+	if len(got) != len(expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+
+	for name, isDir := range expected {
+		if v, exists := got[name]; !exists || v != isDir {
+			t.Fatalf("got %v, expected %v", got, expected)
+		}
+	}
+
+	_, err = d.ReadDir(path.Join(dir, "missing"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+}
+
+func testStage(t *testing.T, d Destination, dir string) {
+	p := path.Join(dir, "01 - Track.flac")
+
+	staged, err := d.Stage(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path.Ext(staged.Path) != ".flac" {
+		t.Fatalf("staged file %q should keep the extension", staged.Path)
+	}
+
+	err = os.WriteFile(staged.Path, []byte("audio"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Stat(p); !os.IsNotExist(err) {
+		t.Fatalf("file is visible before commit: %v", err)
+	}
+
+	err = staged.Commit()
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	// NOTE(patrik): Discard after Commit should leave the file alone
+	staged.Discard()
+
+	if got := readFile(t, d, p); got != "audio" {
+		t.Fatalf("got %q, expected %q", got, "audio")
+	}
+
+	discarded, err := d.Stage(path.Join(dir, "02 - Track.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(discarded.Path, []byte("partial"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discarded.Discard()
+
+	if _, err := os.Stat(discarded.Path); !os.IsNotExist(err) {
+		t.Fatalf("staged file not removed: %v", err)
+	}
+
+	names := readDirNames(t, d, dir)
+	if len(names) != 1 || names[0] != "01 - Track.flac" {
+		t.Fatalf("expected only the committed file, got %v", names)
+	}
+}
@@ -0,0 +1,98 @@
+package destination
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// Local is the local filesystem
+type Local struct{}
+
+func (Local) MkdirAll(p string) error {
+	return os.MkdirAll(p, 0755)
+}
+
+func (Local) WriteFile(p string, r io.Reader) error {
+	f, err := os.CreateTemp(path.Dir(p), tempPattern(""))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(f.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+func (Local) Open(p string) (io.ReadCloser, error) {
+	return os.Open(p)
+}
+
+func (Local) Stat(p string) (fs.FileInfo, error) {
+	return os.Stat(p)
+}
+
+func (Local) Remove(p string) error {
+	return os.RemoveAll(p)
+}
+
+func (Local) ReadDir(p string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, info)
+	}
+
+	return res, nil
+}
+
+// NOTE(patrik): The file is produced next to p with a temporary name, the
+// extension is kept so ffmpeg can pick the output format from it
+func (Local) Stage(p string) (*Staged, error) {
+	f, err := os.CreateTemp(path.Dir(p), tempPattern(path.Ext(p)))
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	staged := &Staged{Path: f.Name()}
+	staged.commit = func() error {
+		err := os.Chmod(staged.Path, 0644)
+		if err != nil {
+			return err
+		}
+
+		return os.Rename(staged.Path, p)
+	}
+
+	return staged, nil
+}
+
+func (Local) Close() error {
+	return nil
+}
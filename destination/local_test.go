@@ -0,0 +1,89 @@
+package destination
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLocalWriteFile(t *testing.T) {
+	testWriteFile(t, Local{}, t.TempDir())
+}
+
+func TestLocalRemove(t *testing.T) {
+	testRemove(t, Local{}, t.TempDir())
+}
+
+func TestLocalReadDir(t *testing.T) {
+	testReadDir(t, Local{}, t.TempDir())
+}
+
+func TestLocalStage(t *testing.T) {
+	testStage(t, Local{}, t.TempDir())
+}
+
+func TestLocalRemoveTempFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	// NOTE(patrik): The pid of a process that has exited, the temporary
+	// files of a crashed export
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadPid := strconv.Itoa(cmd.Process.Pid)
+	livePid := strconv.Itoa(os.Getppid())
+
+	old := time.Now().Add(-TempMaxAge - time.Minute)
+	now := time.Now()
+
+	files := []struct {
+		name    string
+		modTime time.Time
+		keep    bool
+	}{
+		{TempPrefix + owner + "-1.opus", old, true},
+		{TempPrefix + localHost + "-" + deadPid + "-2.opus", now, false},
+		{TempPrefix + localHost + "-" + livePid + "-3.opus", now, true},
+		{TempPrefix + "otherhost-1-4.opus", now, true},
+		{TempPrefix + "otherhost-1-5.opus", old, false},
+		{TempPrefix + "left.opus", old, false},
+		{TempPrefix + "writing.opus", now, true},
+		{"01 - Track.opus", old, true},
+	}
+
+	for _, file := range files {
+		p := filepath.Join(dir, file.name)
+
+		err := os.WriteFile(p, []byte(file.name), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chtimes(p, file.modTime, file.modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = RemoveTempFiles(Local{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		_, err := os.Stat(filepath.Join(dir, file.name))
+		if exists := err == nil; exists != file.keep {
+			t.Errorf("%s: exists %v, expected %v", file.name, exists, file.keep)
+		}
+	}
+
+	err = RemoveTempFiles(Local{}, filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatalf("missing directory: %v", err)
+	}
+}
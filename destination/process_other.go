@@ -0,0 +1,9 @@
+//go:build !unix
+
+package destination
+
+// NOTE(patrik): Without a way to check the process the temporary files is
+// treated as live, RemoveTempFiles leaves them alone
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package destination
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports if the process is running, processes owned by
+// other users is running too
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,280 @@
+package destination
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTP is a remote filesystem accessed over SSH
+type SFTP struct {
+	client *sftp.Client
+
+	// NOTE(patrik): nil when created with NewSFTP
+	conn *ssh.Client
+
+	// Local files of the staged outputs not yet uploaded, removed by Close
+	// if the caller never committed or discarded them
+	mu     sync.Mutex
+	staged map[string]bool
+
+	cleanLocal sync.Once
+}
+
+// NewSFTP creates the destination from an already connected client, the
+// client is closed by Close
+func NewSFTP(client *sftp.Client) *SFTP {
+	return &SFTP{client: client}
+}
+
+var keyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// authMethods returns the SSH agent and the unencrypted keys inside
+// ~/.ssh, the password is used if the URL has one
+func authMethods(u *url.URL) []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+
+	if password, ok := u.User.Password(); ok {
+		methods = append(methods, ssh.Password(password))
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return methods
+	}
+
+	var signers []ssh.Signer
+	for _, name := range keyFiles {
+		data, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+
+		// NOTE(patrik): Encrypted keys needs the agent
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			continue
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	return methods
+}
+
+// DialSFTP connects to the host in the URL, the host key is checked
+// against ~/.ssh/known_hosts
+func DialSFTP(u *url.URL) (*SFTP, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+
+	username := u.User.Username()
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		username = current.Username
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+
+	if _, err := strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("invalid port: %s", port)
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(u.Hostname(), port), &ssh.ClientConfig{
+		User:            username,
+		Auth:            authMethods(u),
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &SFTP{client: client, conn: conn}, nil
+}
+
+func (d *SFTP) MkdirAll(p string) error {
+	return d.client.MkdirAll(p)
+}
+
+func (d *SFTP) WriteFile(p string, r io.Reader) error {
+	tmp := path.Join(path.Dir(p), tempName(""))
+
+	f, err := d.client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.ReadFrom(r)
+	if err != nil {
+		f.Close()
+		d.client.Remove(tmp)
+		return err
+	}
+
+	err = f.Close()
+	if err == nil {
+		err = d.client.Chmod(tmp, 0644)
+	}
+
+	if err == nil {
+		err = d.rename(tmp, p)
+	}
+
+	if err != nil {
+		d.client.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// NOTE(patrik): The plain SFTP rename fails if the target exists, servers
+// without the posix-rename extension gets the target removed first
+func (d *SFTP) rename(oldname, newname string) error {
+	if _, ok := d.client.HasExtension("posix-rename@openssh.com"); ok {
+		return d.client.PosixRename(oldname, newname)
+	}
+
+	err := d.client.Remove(newname)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return d.client.Rename(oldname, newname)
+}
+
+func (d *SFTP) Open(p string) (io.ReadCloser, error) {
+	return d.client.Open(p)
+}
+
+func (d *SFTP) Stat(p string) (fs.FileInfo, error) {
+	return d.client.Stat(p)
+}
+
+func (d *SFTP) Remove(p string) error {
+	info, err := d.client.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return d.client.RemoveAll(p)
+	}
+
+	return d.client.Remove(p)
+}
+
+func (d *SFTP) ReadDir(p string) ([]fs.FileInfo, error) {
+	return d.client.ReadDir(p)
+}
+
+// NOTE(patrik): The file is produced locally and uploaded on commit, the
+// local files left by crashed exports is removed the first time
+func (d *SFTP) Stage(p string) (*Staged, error) {
+	d.cleanLocal.Do(func() {
+		err := RemoveTempFiles(Local{}, os.TempDir())
+		if err != nil {
+			slog.Warn("Failed to remove old staged files", "dir", os.TempDir(), "err", err)
+		}
+	})
+
+	f, err := os.CreateTemp("", tempPattern(path.Ext(p)))
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	d.mu.Lock()
+	if d.staged == nil {
+		d.staged = make(map[string]bool)
+	}
+	d.staged[f.Name()] = true
+	d.mu.Unlock()
+
+	staged := &Staged{Path: f.Name()}
+	staged.commit = func() error {
+		defer d.removeStaged(staged.Path)
+		return Put(d, staged.Path, p)
+	}
+	staged.discard = func() {
+		d.removeStaged(staged.Path)
+	}
+
+	return staged, nil
+}
+
+func (d *SFTP) removeStaged(p string) {
+	os.Remove(p)
+
+	d.mu.Lock()
+	delete(d.staged, p)
+	d.mu.Unlock()
+}
+
+func (d *SFTP) Close() error {
+	d.mu.Lock()
+	for p := range d.staged {
+		os.Remove(p)
+	}
+	d.staged = nil
+	d.mu.Unlock()
+
+	err := d.client.Close()
+	if d.conn != nil {
+		d.conn.Close()
+	}
+
+	return err
+}
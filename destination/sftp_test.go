@@ -0,0 +1,106 @@
+package destination
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// newTestSFTP connects to an in-memory SFTP server, the extensions is the
+// extensions the server reports
+func newTestSFTP(t *testing.T, extensions ...string) *SFTP {
+	t.Helper()
+
+	err := sftp.SetSFTPExtensions(extensions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+	})
+
+	serverConn, clientConn := net.Pipe()
+
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler())
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewSFTP(client)
+	t.Cleanup(func() {
+		d.Close()
+		server.Close()
+	})
+
+	err = d.MkdirAll("/music")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestSFTPWriteFile(t *testing.T) {
+	d := newTestSFTP(t, "posix-rename@openssh.com")
+
+	if _, ok := d.client.HasExtension("posix-rename@openssh.com"); !ok {
+		t.Fatal("expected the server to support posix-rename")
+	}
+
+	testWriteFile(t, d, "/music")
+}
+
+func TestSFTPWriteFileWithoutPosixRename(t *testing.T) {
+	d := newTestSFTP(t)
+
+	if _, ok := d.client.HasExtension("posix-rename@openssh.com"); ok {
+		t.Fatal("expected the server to not support posix-rename")
+	}
+
+	testWriteFile(t, d, "/music")
+}
+
+func TestSFTPRemove(t *testing.T) {
+	testRemove(t, newTestSFTP(t), "/music")
+}
+
+func TestSFTPReadDir(t *testing.T) {
+	testReadDir(t, newTestSFTP(t), "/music")
+}
+
+func TestSFTPStage(t *testing.T) {
+	testStage(t, newTestSFTP(t), "/music")
+}
+
+func TestSFTPStageClose(t *testing.T) {
+	d := newTestSFTP(t)
+
+	staged, err := d.Stage("/music/01 - Track.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discarded, err := d.Stage("/music/02 - Track.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discarded.Discard()
+
+	if _, err := os.Stat(discarded.Path); !os.IsNotExist(err) {
+		t.Errorf("discarded file still exists: %v", err)
+	}
+
+	// NOTE(patrik): Staged files never committed or discarded is removed
+	// when the destination is closed
+	d.Close()
+
+	if _, err := os.Stat(staged.Path); !os.IsNotExist(err) {
+		t.Errorf("staged file still exists after close: %v", err)
+	}
+}
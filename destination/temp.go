@@ -0,0 +1,72 @@
+package destination
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NOTE(patrik): The temporary files is named
+// <TempPrefix><host>-<pid>-<random><ext> so the next run can tell the
+// files of crashed exports from the files other exports is still writing
+
+var localHost = hostID()
+
+// owner identifies the temporary files written by this process
+var owner = localHost + "-" + strconv.Itoa(os.Getpid())
+
+// hostID returns a short hash of the host name, the host name itself can
+// contain the separators
+func hostID() string {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "unknown"
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// tempPattern returns the os.CreateTemp pattern for the temporary files of
+// this process
+func tempPattern(ext string) string {
+	return TempPrefix + owner + "-*" + ext
+}
+
+// tempName returns a new name for a temporary file of this process
+func tempName(ext string) string {
+	return TempPrefix + owner + "-" + strconv.FormatInt(time.Now().UnixNano(), 36) + ext
+}
+
+// TempMaxAge is how long a temporary file written from another host can go
+// without being modified before RemoveTempFiles sees it as left behind
+const TempMaxAge = 6 * time.Hour
+
+// isStaleTemp reports if the temporary file was left behind by an export
+// that is no longer running
+func isStaleTemp(info fs.FileInfo) bool {
+	name := strings.TrimPrefix(info.Name(), TempPrefix)
+
+	host, rest, _ := strings.Cut(name, "-")
+	pidStr, _, _ := strings.Cut(rest, "-")
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil || host != localHost {
+		// NOTE(patrik): We can't check if the process on another host
+		// (or from before the owner was added to the names) is still
+		// running, only the age is left
+		return time.Since(info.ModTime()) >= TempMaxAge
+	}
+
+	if pid == os.Getpid() {
+		return false
+	}
+
+	return !processAlive(pid)
+}
@@ -0,0 +1,103 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const CacheFile = "fingerprints.toml"
+
+type CacheEntry struct {
+	Size        int64  `toml:"size"`
+	ModTime     int64  `toml:"modtime"`
+	Fingerprint string `toml:"fingerprint"`
+}
+
+// Cache is stored next to album.toml, the entries is keyed by the file name
+// relative to the album directory (with the sample range for split tracks)
+type Cache struct {
+	Files map[string]CacheEntry `toml:"files"`
+}
+
+func LoadCache(dir string) (Cache, error) {
+	p := path.Join(dir, CacheFile)
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Cache{Files: make(map[string]CacheEntry)}, nil
+		}
+
+		return Cache{}, err
+	}
+
+	var cache Cache
+	err = toml.Unmarshal(data, &cache)
+	if err != nil {
+		return Cache{}, fmt.Errorf("%s: %w", p, err)
+	}
+
+	if cache.Files == nil {
+		cache.Files = make(map[string]CacheEntry)
+	}
+
+	return cache, nil
+}
+
+func (c *Cache) Save(dir string) error {
+	data, err := toml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(dir, CacheFile), data, 0644)
+}
+
+// cacheKey returns the key of the entry, the range is only included for
+// split tracks so the keys of whole files is just the name
+func cacheKey(name string, start, end int64) string {
+	if start == 0 && end == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("%s#%d-%d", name, start, end)
+}
+
+// Get returns the fingerprint for the range of the file (end 0 is the end
+// of the file), the fingerprint is calculated if the cached entry is
+// missing or the file has changed, returns true if the cache was updated
+func (c *Cache) Get(dir, name string, start, end int64) ([]uint32, bool, error) {
+	p := path.Join(dir, name)
+	key := cacheKey(name, start, end)
+
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry, exists := c.Files[key]
+	if exists && entry.Size == stat.Size() && entry.ModTime == stat.ModTime().Unix() {
+		fp, err := Decode(entry.Fingerprint)
+		if err == nil {
+			return fp, false, nil
+		}
+	}
+
+	fp, err := CalculateRange(p, start, end)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.Files[key] = CacheEntry{
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime().Unix(),
+		Fingerprint: Encode(fp),
+	}
+
+	return fp, true, nil
+}
@@ -0,0 +1,284 @@
+package fingerprint
+
+import (
+	"math"
+
+	"github.com/nanoteck137/slurpuff/audio"
+)
+
+// NOTE(patrik): Implementation of the default Chromaprint algorithm
+// (CHROMAPRINT_ALGORITHM_TEST2) so the fingerprints is compatible with
+// fpcalc and AcoustID
+
+const (
+	SampleRate = 11025
+
+	// Same as the default length fpcalc uses
+	MaxDuration = 120
+
+	Algorithm = 1
+
+	frameSize    = 4096
+	frameOverlap = frameSize - frameSize/3
+	frameStep    = frameSize - frameOverlap
+
+	minFreq  = 28
+	maxFreq  = 3520
+	numBands = 12
+)
+
+var chromaFilterCoefficients = []float64{0.25, 0.75, 1.0, 0.75, 0.25}
+
+type filter struct {
+	kind   int
+	y      int
+	height int
+	width  int
+}
+
+type quantizer struct {
+	t0, t1, t2 float64
+}
+
+type classifier struct {
+	filter    filter
+	quantizer quantizer
+}
+
+var classifiers = []classifier{
+	{filter{0, 4, 3, 15}, quantizer{1.98215, 2.35817, 2.63523}},
+	{filter{4, 4, 6, 15}, quantizer{-1.03809, -0.651211, -0.282167}},
+	{filter{1, 0, 4, 16}, quantizer{-0.298702, 0.119262, 0.558497}},
+	{filter{3, 8, 2, 12}, quantizer{-0.105439, 0.0153946, 0.135898}},
+	{filter{3, 4, 4, 8}, quantizer{-0.142891, 0.0258736, 0.200632}},
+	{filter{4, 0, 3, 5}, quantizer{-0.826319, -0.590612, -0.368214}},
+	{filter{1, 2, 2, 9}, quantizer{-0.557409, -0.233035, 0.0534525}},
+	{filter{2, 7, 3, 4}, quantizer{-0.0646826, 0.00620476, 0.0784847}},
+	{filter{2, 6, 2, 16}, quantizer{-0.192387, -0.029699, 0.215855}},
+	{filter{2, 1, 3, 2}, quantizer{-0.0397818, -0.00568076, 0.0292026}},
+	{filter{5, 10, 1, 15}, quantizer{-0.53823, -0.369934, -0.190235}},
+	{filter{3, 6, 2, 10}, quantizer{-0.124877, 0.0296483, 0.139239}},
+	{filter{2, 1, 1, 14}, quantizer{-0.101475, 0.0225617, 0.231971}},
+	{filter{3, 5, 6, 4}, quantizer{-0.0799915, -0.00729616, 0.063262}},
+	{filter{1, 9, 2, 12}, quantizer{-0.272556, 0.019424, 0.302559}},
+	{filter{3, 4, 2, 14}, quantizer{-0.164292, -0.0321188, 0.0846339}},
+}
+
+const maxFilterWidth = 16
+
+var grayCode = []uint32{0, 1, 3, 2}
+
+func freqToIndex(freq float64) int {
+	return int(math.Round(frameSize * freq / SampleRate))
+}
+
+// chroma converts the audio into 12 band chroma features, one row per
+// frame
+func chroma(samples []int16) [][]float64 {
+	minIndex := max(1, freqToIndex(minFreq))
+	maxIndex := min(frameSize/2, freqToIndex(maxFreq))
+
+	notes := make([]int, maxIndex)
+	for i := minIndex; i < maxIndex; i++ {
+		freq := float64(i) * SampleRate / frameSize
+		octave := math.Log2(freq / (440.0 / 16.0))
+		notes[i] = int(numBands * (octave - math.Floor(octave)))
+	}
+
+	window := audio.HammingWindow(frameSize)
+	frame := make([]float64, frameSize)
+	buf := make([]complex128, frameSize)
+
+	var res [][]float64
+	for start := 0; start+frameSize <= len(samples); start += frameStep {
+		for i := range frame {
+			frame[i] = float64(samples[start+i])
+		}
+
+		spectrum := audio.PowerSpectrum(frame, window, 1.0/32768.0, buf)
+
+		features := make([]float64, numBands)
+		for i := minIndex; i < maxIndex; i++ {
+			features[notes[i]] += spectrum[i]
+		}
+
+		res = append(res, features)
+	}
+
+	return res
+}
+
+func filterChroma(rows [][]float64) [][]float64 {
+	n := len(chromaFilterCoefficients)
+	if len(rows) < n {
+		return nil
+	}
+
+	res := make([][]float64, 0, len(rows)-n+1)
+	for i := 0; i+n <= len(rows); i++ {
+		features := make([]float64, numBands)
+		for j, c := range chromaFilterCoefficients {
+			for b := range features {
+				features[b] += c * rows[i+j][b]
+			}
+		}
+
+		res = append(res, features)
+	}
+
+	return res
+}
+
+func normalize(rows [][]float64) {
+	for _, row := range rows {
+		norm := 0.0
+		for _, v := range row {
+			norm += v * v
+		}
+		norm = math.Sqrt(norm)
+
+		for i := range row {
+			if norm < 0.01 {
+				row[i] = 0
+			} else {
+				row[i] /= norm
+			}
+		}
+	}
+}
+
+type integralImage struct {
+	rows [][]float64
+}
+
+func newIntegralImage(rows [][]float64) integralImage {
+	res := make([][]float64, len(rows))
+
+	for r, row := range rows {
+		res[r] = make([]float64, numBands)
+
+		sum := 0.0
+		for c, v := range row {
+			sum += v
+			res[r][c] = sum
+			if r > 0 {
+				res[r][c] += res[r-1][c]
+			}
+		}
+	}
+
+	return integralImage{rows: res}
+}
+
+func (img integralImage) area(r1, c1, r2, c2 int) float64 {
+	if r1 == r2 || c1 == c2 {
+		return 0
+	}
+
+	res := img.rows[r2-1][c2-1]
+	if r1 > 0 {
+		res -= img.rows[r1-1][c2-1]
+	}
+
+	if c1 > 0 {
+		res -= img.rows[r2-1][c1-1]
+	}
+
+	if r1 > 0 && c1 > 0 {
+		res += img.rows[r1-1][c1-1]
+	}
+
+	return res
+}
+
+func subtractLog(a, b float64) float64 {
+	return math.Log((1.0 + a) / (1.0 + b))
+}
+
+func (f filter) apply(img integralImage, x int) float64 {
+	y, w, h := f.y, f.width, f.height
+
+	switch f.kind {
+	case 0:
+		return subtractLog(img.area(x, y, x+w, y+h), 0)
+	case 1:
+		h2 := h / 2
+		a := img.area(x, y+h2, x+w, y+h)
+		b := img.area(x, y, x+w, y+h2)
+		return subtractLog(a, b)
+	case 2:
+		w2 := w / 2
+		a := img.area(x+w2, y, x+w, y+h)
+		b := img.area(x, y, x+w2, y+h)
+		return subtractLog(a, b)
+	case 3:
+		w2, h2 := w/2, h/2
+		a := img.area(x, y+h2, x+w2, y+h) + img.area(x+w2, y, x+w, y+h2)
+		b := img.area(x, y, x+w2, y+h2) + img.area(x+w2, y+h2, x+w, y+h)
+		return subtractLog(a, b)
+	case 4:
+		h3 := h / 3
+		a := img.area(x, y+h3, x+w, y+2*h3)
+		b := img.area(x, y, x+w, y+h3) + img.area(x, y+2*h3, x+w, y+h)
+		return subtractLog(a, b)
+	case 5:
+		w3 := w / 3
+		a := img.area(x+w3, y, x+2*w3, y+h)
+		b := img.area(x, y, x+w3, y+h) + img.area(x+2*w3, y, x+w, y+h)
+		return subtractLog(a, b)
+	}
+
+	return 0
+}
+
+func (q quantizer) quantize(v float64) int {
+	if v < q.t1 {
+		if v < q.t0 {
+			return 0
+		}
+
+		return 1
+	}
+
+	if v < q.t2 {
+		return 2
+	}
+
+	return 3
+}
+
+// Calculate returns the raw fingerprint for mono samples at SampleRate
+func Calculate(samples []int16) []uint32 {
+	rows := filterChroma(chroma(samples))
+	normalize(rows)
+
+	if len(rows) < maxFilterWidth {
+		return nil
+	}
+
+	img := newIntegralImage(rows)
+
+	res := make([]uint32, 0, len(rows)-maxFilterWidth+1)
+	for x := 0; x+maxFilterWidth <= len(rows); x++ {
+		var bits uint32
+		for _, c := range classifiers {
+			bits = (bits << 2) | grayCode[c.quantizer.quantize(c.filter.apply(img, x))]
+		}
+
+		res = append(res, bits)
+	}
+
+	return res
+}
+
+// CalculateRange decodes the first MaxDuration seconds of the part of the
+// file between the start and end sample with ffmpeg and returns the raw
+// fingerprint, start and end 0 is the whole file
+func CalculateRange(p string, start, end int64) ([]uint32, error) {
+	samples, err := audio.DecodeMonoRange(p, start, end, SampleRate, MaxDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return Calculate(samples), nil
+}
@@ -0,0 +1,47 @@
+package fingerprint
+
+import "math/bits"
+
+// NOTE(patrik): About 10 seconds in both directions, the same recording
+// can have different amounts of silence at the start
+const maxAlignOffset = 80
+
+// Minimum number of overlapping items needed for a comparison (about 5
+// seconds)
+const minOverlap = 40
+
+// Similarity returns the best match between the fingerprints in the range
+// 0.0 - 1.0, unrelated audio usually ends up around 0.5
+func Similarity(a, b []uint32) float64 {
+	best := 0.0
+
+	for offset := -maxAlignOffset; offset <= maxAlignOffset; offset++ {
+		errors := 0
+		count := 0
+
+		for i := range a {
+			j := i + offset
+			if j < 0 {
+				continue
+			}
+
+			if j >= len(b) {
+				break
+			}
+
+			errors += bits.OnesCount32(a[i] ^ b[j])
+			count++
+		}
+
+		if count < minOverlap {
+			continue
+		}
+
+		score := 1.0 - float64(errors)/float64(count*32)
+		if score > best {
+			best = score
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,101 @@
+package fingerprint
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomFingerprint(r *rand.Rand, size int) []uint32 {
+	fp := make([]uint32, size)
+	for i := range fp {
+		fp[i] = r.Uint32()
+	}
+
+	return fp
+}
+
+func TestSimilarity(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	a := randomFingerprint(r, 500)
+	b := randomFingerprint(r, 500)
+
+	// NOTE(patrik): The same fingerprint with 2 extra seconds at the start
+	shifted := append(randomFingerprint(r, 16), a...)
+
+	// One bit changed in every item
+	flipped := make([]uint32, len(a))
+	for i, v := range a {
+		flipped[i] = v ^ (1 << (i % 32))
+	}
+
+	tests := []struct {
+		name     string
+		a, b     []uint32
+		min, max float64
+	}{
+		{"identical", a, a, 1, 1},
+		{"shifted", a, shifted, 1, 1},
+		{"shifted reversed", shifted, a, 1, 1},
+		{"one bit", a, flipped, 1 - 1.0/32, 1 - 1.0/32},
+		{"unrelated", a, b, 0.45, 0.6},
+		{"too short", a[:minOverlap-1], a[:minOverlap-1], 0, 0},
+		{"empty", nil, a, 0, 0},
+	}
+
+	for _, test := range tests {
+		score := Similarity(test.a, test.b)
+		if score < test.min-1e-9 || score > test.max+1e-9 {
+			t.Errorf("%s: got %f, expected %f - %f", test.name, score, test.min, test.max)
+		}
+	}
+}
+
+// melody returns mono samples at SampleRate playing a new tone every half
+// second
+func melody(notes []float64, seconds int, noise float64, seed int64) []int16 {
+	r := rand.New(rand.NewSource(seed))
+
+	samples := make([]int16, seconds*SampleRate)
+	for i := range samples {
+		note := notes[(i/(SampleRate/2))%len(notes)]
+		v := 0.5*math.Sin(2*math.Pi*note*float64(i)/SampleRate) + noise*(r.Float64()*2-1)
+		samples[i] = int16(v * math.MaxInt16)
+	}
+
+	return samples
+}
+
+func TestCalculate(t *testing.T) {
+	notes := []float64{261.63, 329.63, 392.00, 523.25, 440.00, 349.23, 293.66, 246.94}
+	other := []float64{220.00, 207.65, 311.13, 277.18, 369.99, 415.30, 466.16, 185.00}
+
+	fp := Calculate(melody(notes, 30, 0, 1))
+
+	// NOTE(patrik): One item for every frame step after the first frame,
+	// less the width of the chroma filter and the widest classifier filter
+	frames := (30*SampleRate-frameSize)/frameStep + 1
+	items := frames - (len(chromaFilterCoefficients) - 1) - (maxFilterWidth - 1)
+	if len(fp) != items {
+		t.Fatalf("got %d items, expected %d", len(fp), items)
+	}
+
+	if again := Calculate(melody(notes, 30, 0, 1)); Similarity(fp, again) != 1 {
+		t.Errorf("fingerprint is not deterministic")
+	}
+
+	noisy := Calculate(melody(notes, 30, 0.05, 2))
+	if score := Similarity(fp, noisy); score < 0.9 {
+		t.Errorf("noisy copy: got %f, expected at least 0.9", score)
+	}
+
+	different := Calculate(melody(other, 30, 0, 1))
+	if score := Similarity(fp, different); score >= 0.8 {
+		t.Errorf("different melody: got %f, expected less than 0.8", score)
+	}
+
+	if res := Calculate(make([]int16, frameSize)); res != nil {
+		t.Errorf("too short: got %d items, expected none", len(res))
+	}
+}
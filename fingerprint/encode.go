@@ -0,0 +1,184 @@
+package fingerprint
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// NOTE(patrik): Same compressed format as Chromaprint uses for the
+// fingerprint strings (chromaprint_encode_fingerprint)
+
+const maxNormalValue = 7
+
+type bitWriter struct {
+	data  []byte
+	value uint32
+	bits  uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.value |= v << w.bits
+	w.bits += n
+
+	for w.bits >= 8 {
+		w.data = append(w.data, byte(w.value))
+		w.value >>= 8
+		w.bits -= 8
+	}
+}
+
+func (w *bitWriter) flush() {
+	if w.bits > 0 {
+		w.data = append(w.data, byte(w.value))
+		w.value = 0
+		w.bits = 0
+	}
+}
+
+type bitReader struct {
+	data  []byte
+	value uint32
+	bits  uint
+}
+
+func (r *bitReader) read(n uint) (uint32, bool) {
+	for r.bits < n {
+		if len(r.data) == 0 {
+			return 0, false
+		}
+
+		r.value |= uint32(r.data[0]) << r.bits
+		r.data = r.data[1:]
+		r.bits += 8
+	}
+
+	v := r.value & ((1 << n) - 1)
+	r.value >>= n
+	r.bits -= n
+
+	return v, true
+}
+
+// alignedRest returns the data left after the partially read byte
+func (r *bitReader) alignedRest() []byte {
+	return r.data
+}
+
+func Encode(fp []uint32) string {
+	var normal []uint32
+	var exceptional []uint32
+
+	var prev uint32
+	for _, v := range fp {
+		x := v ^ prev
+		prev = v
+
+		bit, lastBit := uint32(1), uint32(0)
+		for x != 0 {
+			if x&1 != 0 {
+				value := bit - lastBit
+				if value >= maxNormalValue {
+					normal = append(normal, maxNormalValue)
+					exceptional = append(exceptional, value-maxNormalValue)
+				} else {
+					normal = append(normal, value)
+				}
+
+				lastBit = bit
+			}
+
+			x >>= 1
+			bit++
+		}
+
+		normal = append(normal, 0)
+	}
+
+	size := len(fp)
+	data := []byte{Algorithm, byte(size >> 16), byte(size >> 8), byte(size)}
+
+	w := bitWriter{data: data}
+	for _, v := range normal {
+		w.write(v, 3)
+	}
+	w.flush()
+
+	for _, v := range exceptional {
+		w.write(v, 5)
+	}
+	w.flush()
+
+	return base64.RawURLEncoding.EncodeToString(w.data)
+}
+
+func Decode(s string) ([]uint32, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint: %w", err)
+	}
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("fingerprint: missing header")
+	}
+
+	size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+
+	r := bitReader{data: data[4:]}
+
+	var normal []uint32
+	exceptionalCount := 0
+	for found := 0; found < size; {
+		v, ok := r.read(3)
+		if !ok {
+			return nil, fmt.Errorf("fingerprint: truncated data")
+		}
+
+		if v == 0 {
+			found++
+		} else if v == maxNormalValue {
+			exceptionalCount++
+		}
+
+		normal = append(normal, v)
+	}
+
+	r = bitReader{data: r.alignedRest()}
+
+	exceptional := make([]uint32, exceptionalCount)
+	for i := range exceptional {
+		v, ok := r.read(5)
+		if !ok {
+			return nil, fmt.Errorf("fingerprint: truncated data")
+		}
+
+		exceptional[i] = v
+	}
+
+	res := make([]uint32, 0, size)
+
+	var prev uint32
+	var x uint32
+	lastBit := uint32(0)
+	e := 0
+	for _, v := range normal {
+		if v == 0 {
+			prev ^= x
+			res = append(res, prev)
+
+			x = 0
+			lastBit = 0
+			continue
+		}
+
+		if v == maxNormalValue {
+			v += exceptional[e]
+			e++
+		}
+
+		bit := lastBit + v
+		x |= 1 << (bit - 1)
+		lastBit = bit
+	}
+
+	return res, nil
+}
@@ -0,0 +1,101 @@
+package fingerprint
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	// NOTE(patrik): Test vectors from the Chromaprint fingerprint
+	// compressor tests, the header starts with the algorithm
+	tests := []struct {
+		name string
+		fp   []uint32
+		data []byte
+	}{
+		{"one item one bit", []uint32{1}, []byte{Algorithm, 0, 0, 1, 0x01}},
+		{"one item three bits", []uint32{7}, []byte{Algorithm, 0, 0, 1, 0x49, 0x00}},
+		{"one item exceptional bit", []uint32{1 << 6}, []byte{Algorithm, 0, 0, 1, 0x07, 0x00}},
+		{"one item exceptional bit 2", []uint32{1 << 8}, []byte{Algorithm, 0, 0, 1, 0x07, 0x02}},
+		{"two items", []uint32{1, 0}, []byte{Algorithm, 0, 0, 2, 0x41, 0x00}},
+		{"two items no change", []uint32{1, 1}, []byte{Algorithm, 0, 0, 2, 0x01, 0x00}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Encode(test.fp)
+
+			expected := base64.RawURLEncoding.EncodeToString(test.data)
+			if s != expected {
+				t.Errorf("got %q, expected %q", s, expected)
+			}
+
+			fp, err := Decode(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(fp, test.fp) {
+				t.Errorf("decoded %v, expected %v", fp, test.fp)
+			}
+		})
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{0, 1, 2, 100, 1000} {
+		fp := make([]uint32, size)
+		for i := range fp {
+			fp[i] = r.Uint32()
+		}
+
+		// NOTE(patrik): Items with only the high bits set is encoded as
+		// exceptional values
+		if size > 2 {
+			fp[1] = 1 << 31
+			fp[2] = 0
+		}
+
+		res, err := Decode(Encode(fp))
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		if len(res) != len(fp) {
+			t.Fatalf("size %d: decoded %d items", size, len(res))
+		}
+
+		for i := range fp {
+			if res[i] != fp[i] {
+				t.Fatalf("size %d: item %d is %08x, expected %08x", size, i, res[i], fp[i])
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	fp := make([]uint32, 10)
+	for i := range fp {
+		fp[i] = uint32(i * 0x01010101)
+	}
+
+	s := Encode(fp)
+
+	tests := []string{
+		"",
+		"AQA",
+		"not base64!",
+		s[:len(s)/2],
+	}
+
+	for _, test := range tests {
+		_, err := Decode(test)
+		if err == nil {
+			t.Errorf("Decode(%q): expected error", test)
+		}
+	}
+}
@@ -4,17 +4,24 @@ go 1.21.6
 
 require (
 	github.com/flytam/filenamify v1.2.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/kr/pretty v0.3.1
 	github.com/nanoteck137/parasect v0.2.1
 	github.com/pelletier/go-toml/v2 v2.2.0
+	github.com/pkg/sftp v1.13.6
 	github.com/spf13/cobra v1.8.0
+	golang.org/x/crypto v0.21.0
+	golang.org/x/image v0.15.0
+	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/rogpeppe/go-internal v1.12.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.18.0 // indirect
 )
@@ -0,0 +1,188 @@
+package id3
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"unicode/utf16"
+)
+
+// NOTE(patrik): Just enough of ID3v2.3/2.4 to add the text frames ffmpeg
+// has no mapping for (TSO2), ffmpeg writes unknown keys as TXXX frames
+// that players ignores
+
+var ErrInvalid = errors.New("invalid id3 tag")
+
+var ErrUnsupported = errors.New("unsupported id3 tag")
+
+const headerSize = 10
+
+// Frame is a text frame, Value is written as UTF-8 for ID3v2.4 and UTF-16
+// for ID3v2.3
+type Frame struct {
+	ID    string
+	Value string
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func putSyncsafe(b []byte, v int) {
+	b[0] = byte(v>>21) & 0x7f
+	b[1] = byte(v>>14) & 0x7f
+	b[2] = byte(v>>7) & 0x7f
+	b[3] = byte(v) & 0x7f
+}
+
+type rawFrame struct {
+	id   string
+	data []byte // the whole frame including the header
+}
+
+// readFrames returns the version, the frames and the end of the tag,
+// version is 0 if the data has no tag
+func readFrames(data []byte) (byte, []rawFrame, int, error) {
+	if len(data) < headerSize || string(data[0:3]) != "ID3" {
+		return 0, nil, 0, nil
+	}
+
+	version := data[3]
+	flags := data[5]
+
+	if version != 3 && version != 4 {
+		return 0, nil, 0, fmt.Errorf("%w: version 2.%d", ErrUnsupported, version)
+	}
+
+	// NOTE(patrik): Unsynchronisation and the footer is never used by
+	// ffmpeg
+	if flags&0x80 != 0 || flags&0x10 != 0 {
+		return 0, nil, 0, fmt.Errorf("%w: flags %02x", ErrUnsupported, flags)
+	}
+
+	end := headerSize + syncsafe(data[6:10])
+	if end > len(data) {
+		return 0, nil, 0, ErrInvalid
+	}
+
+	pos := headerSize
+
+	if flags&0x40 != 0 {
+		if pos+4 > end {
+			return 0, nil, 0, ErrInvalid
+		}
+
+		// NOTE(patrik): The size of the extended header includes itself
+		// in 2.4 but not in 2.3
+		if version == 4 {
+			pos += syncsafe(data[pos:])
+		} else {
+			pos += 4 + int(binary.BigEndian.Uint32(data[pos:]))
+		}
+	}
+
+	var frames []rawFrame
+	for pos+headerSize <= end {
+		// Padding
+		if data[pos] == 0 {
+			break
+		}
+
+		size := int(binary.BigEndian.Uint32(data[pos+4:]))
+		if version == 4 {
+			size = syncsafe(data[pos+4:])
+		}
+
+		frameEnd := pos + headerSize + size
+		if frameEnd > end {
+			return 0, nil, 0, ErrInvalid
+		}
+
+		frames = append(frames, rawFrame{
+			id:   string(data[pos : pos+4]),
+			data: data[pos:frameEnd],
+		})
+
+		pos = frameEnd
+	}
+
+	return version, frames, end, nil
+}
+
+func encodeFrame(version byte, frame Frame) []byte {
+	var payload []byte
+
+	if version == 4 {
+		// UTF-8
+		payload = append([]byte{3}, frame.Value...)
+	} else {
+		// UTF-16 with a little endian BOM
+		payload = []byte{1, 0xff, 0xfe}
+		for _, c := range utf16.Encode([]rune(frame.Value)) {
+			payload = binary.LittleEndian.AppendUint16(payload, c)
+		}
+	}
+
+	res := make([]byte, headerSize, headerSize+len(payload))
+	copy(res, frame.ID)
+
+	if version == 4 {
+		putSyncsafe(res[4:], len(payload))
+	} else {
+		binary.BigEndian.PutUint32(res[4:], uint32(len(payload)))
+	}
+
+	return append(res, payload...)
+}
+
+// WriteTextFrames adds the frames to the ID3v2 tag of the file, frames
+// with the same id is replaced. A ID3v2.4 tag is created if the file has
+// none.
+func WriteTextFrames(p string, frames []Frame) error {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	version, existing, end, err := readFrames(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+
+	if version == 0 {
+		version = 4
+	}
+
+	replaced := make(map[string]bool)
+	for _, frame := range frames {
+		replaced[frame.ID] = true
+	}
+
+	var body []byte
+	for _, frame := range existing {
+		if !replaced[frame.id] {
+			body = append(body, frame.data...)
+		}
+	}
+
+	for _, frame := range frames {
+		body = append(body, encodeFrame(version, frame)...)
+	}
+
+	// NOTE(patrik): The extended header and padding of the old tag is
+	// dropped
+	header := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(header[6:], len(body))
+
+	res := make([]byte, 0, len(header)+len(body)+len(data)-end)
+	res = append(res, header...)
+	res = append(res, body...)
+	res = append(res, data[end:]...)
+
+	return os.WriteFile(p, res, 0644)
+}
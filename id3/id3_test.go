@@ -0,0 +1,151 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+	"unicode/utf16"
+)
+
+var audio = []byte{0xff, 0xfb, 0x90, 0x64, 1, 2, 3, 4}
+
+// makeTag returns a tag with the frames and padding, the frames is UTF-8
+// (2.4) or ISO-8859-1 (2.3) text frames
+func makeTag(version byte, frames map[string]string, padding int) []byte {
+	var body []byte
+	for _, id := range []string{"TIT2", "TPE1", "TSO2"} {
+		value, exists := frames[id]
+		if !exists {
+			continue
+		}
+
+		enc := byte(0)
+		if version == 4 {
+			enc = 3
+		}
+
+		payload := append([]byte{enc}, value...)
+
+		header := make([]byte, headerSize)
+		copy(header, id)
+		if version == 4 {
+			putSyncsafe(header[4:], len(payload))
+		} else {
+			binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+		}
+
+		body = append(body, header...)
+		body = append(body, payload...)
+	}
+
+	body = append(body, make([]byte, padding)...)
+
+	header := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(header[6:], len(body))
+
+	return append(header, body...)
+}
+
+// textValue decodes the text of a frame written by encodeFrame
+func textValue(t *testing.T, frame rawFrame) string {
+	payload := frame.data[headerSize:]
+
+	switch payload[0] {
+	case 0, 3:
+		return string(payload[1:])
+	case 1:
+		if !bytes.HasPrefix(payload[1:], []byte{0xff, 0xfe}) {
+			t.Fatalf("%s: missing BOM", frame.id)
+		}
+
+		units := make([]uint16, 0, (len(payload)-3)/2)
+		for i := 3; i+1 < len(payload); i += 2 {
+			units = append(units, binary.LittleEndian.Uint16(payload[i:]))
+		}
+
+		return string(utf16.Decode(units))
+	}
+
+	t.Fatalf("%s: unknown encoding %d", frame.id, payload[0])
+	return ""
+}
+
+func TestWriteTextFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		version byte
+		frames  map[string]string
+	}{
+		{
+			name:    "2.4",
+			data:    append(makeTag(4, map[string]string{"TIT2": "Title", "TPE1": "Artist"}, 16), audio...),
+			version: 4,
+			frames:  map[string]string{"TIT2": "Title", "TPE1": "Artist", "TSO2": "Beatles, The"},
+		},
+		{
+			name:    "2.3",
+			data:    append(makeTag(3, map[string]string{"TIT2": "Title"}, 0), audio...),
+			version: 3,
+			frames:  map[string]string{"TIT2": "Title", "TSO2": "Beatles, The"},
+		},
+		{
+			name:    "replaced",
+			data:    append(makeTag(4, map[string]string{"TIT2": "Title", "TSO2": "Old"}, 16), audio...),
+			version: 4,
+			frames:  map[string]string{"TIT2": "Title", "TSO2": "Beatles, The"},
+		},
+		{
+			name:    "no tag",
+			data:    audio,
+			version: 4,
+			frames:  map[string]string{"TSO2": "Beatles, The"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := path.Join(t.TempDir(), "track.mp3")
+
+			err := os.WriteFile(p, test.data, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = WriteTextFrames(p, []Frame{{ID: "TSO2", Value: "Beatles, The"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			version, frames, end, err := readFrames(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != test.version {
+				t.Errorf("got version %d, expected %d", version, test.version)
+			}
+
+			if !bytes.Equal(data[end:], audio) {
+				t.Errorf("audio data changed")
+			}
+
+			if len(frames) != len(test.frames) {
+				t.Errorf("got %d frames, expected %d", len(frames), len(test.frames))
+			}
+
+			for _, frame := range frames {
+				if value := textValue(t, frame); value != test.frames[frame.id] {
+					t.Errorf("%s: got %q, expected %q", frame.id, value, test.frames[frame.id])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package lookup
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/types"
+)
+
+type Artist struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	SortName string `json:"sort_name"`
+}
+
+type Track struct {
+	Position    int      `json:"position"`
+	Title       string   `json:"title"`
+	Length      int      `json:"length"`
+	RecordingID string   `json:"recording_id"`
+	ISRC        string   `json:"isrc"`
+	Artists     []Artist `json:"artists"`
+}
+
+type Medium struct {
+	Position int     `json:"position"`
+	Tracks   []Track `json:"tracks"`
+}
+
+type Release struct {
+	ID             string   `json:"id"`
+	ReleaseGroupID string   `json:"release_group_id"`
+	Title          string   `json:"title"`
+	Artist         string   `json:"artist"`
+	Artists        []Artist `json:"artists"`
+	Year           int      `json:"year"`
+	Type           string   `json:"type"`
+	Barcode        string   `json:"barcode"`
+	Media          []Medium `json:"media"`
+}
+
+func (r *Release) TrackCount() int {
+	count := 0
+	for _, medium := range r.Media {
+		count += len(medium.Tracks)
+	}
+
+	return count
+}
+
+type Query struct {
+	Album  string
+	Artist string
+
+	// Track durations in seconds in album order
+	Durations []int
+}
+
+type Provider interface {
+	Name() string
+
+	// Search returns the candidate releases for the query, the result is
+	// ranked with Rank
+	Search(query Query) ([]Release, error)
+}
+
+type Match struct {
+	Release Release
+
+	// Average difference in seconds between the track durations
+	Distance float64
+	Score    float64
+}
+
+// NOTE(patrik): Tracks that differ more than this is most likely not the
+// same recording
+const maxTrackDistance = 5
+
+func distance(query Query, release Release) (float64, bool) {
+	total := 0.0
+	i := 0
+
+	for _, medium := range release.Media {
+		for _, track := range medium.Tracks {
+			// NOTE(patrik): Some releases is missing track lengths
+			if track.Length == 0 || query.Durations[i] == 0 {
+				total += maxTrackDistance / 2.0
+				i++
+				continue
+			}
+
+			d := math.Abs(float64(track.Length - query.Durations[i]))
+			if d > maxTrackDistance {
+				return 0, false
+			}
+
+			total += d
+			i++
+		}
+	}
+
+	return total / float64(len(query.Durations)), true
+}
+
+// Rank returns the releases that matches the query sorted by score, best
+// match first
+func Rank(query Query, releases []Release) []Match {
+	var matches []Match
+
+	for _, release := range releases {
+		if release.TrackCount() != len(query.Durations) || len(query.Durations) == 0 {
+			continue
+		}
+
+		dist, ok := distance(query, release)
+		if !ok {
+			continue
+		}
+
+		score := 1.0 - dist/maxTrackDistance
+
+		if query.Album != "" && strings.EqualFold(query.Album, release.Title) {
+			score += 0.5
+		}
+
+		if query.Artist != "" && strings.EqualFold(query.Artist, release.Artist) {
+			score += 0.5
+		}
+
+		matches = append(matches, Match{
+			Release:  release,
+			Distance: dist,
+			Score:    score,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches
+}
+
+func QueryFromMetadata(metadata types.AlbumMetadata) Query {
+	tracks := sortedTracks(metadata)
+
+	durations := make([]int, len(tracks))
+	for i, track := range tracks {
+		durations[i] = metadata.Tracks[track].Duration
+	}
+
+	return Query{
+		Album:     metadata.Album,
+		Artist:    metadata.Artist,
+		Durations: durations,
+	}
+}
+
+// sortedTracks returns the track indices sorted by disc and track number
+func sortedTracks(metadata types.AlbumMetadata) []int {
+	res := make([]int, len(metadata.Tracks))
+	for i := range res {
+		res[i] = i
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		a := metadata.Tracks[res[i]]
+		b := metadata.Tracks[res[j]]
+
+		if a.Disc != b.Disc {
+			return a.Disc < b.Disc
+		}
+
+		return a.Num < b.Num
+	})
+
+	return res
+}
+
+// Apply returns a copy of metadata with the information from the release,
+// the release needs to have the same number of tracks as metadata
+func Apply(metadata types.AlbumMetadata, release Release) types.AlbumMetadata {
+	res := metadata
+	res.Tracks = make([]types.TrackMetadata, len(metadata.Tracks))
+	copy(res.Tracks, metadata.Tracks)
+
+	res.Album = release.Title
+	res.Artist = release.Artist
+
+	// NOTE(patrik): The alternate name is for the old artist
+	if res.Artist != metadata.Artist {
+		res.ArtistAlt = ""
+	}
+	res.MusicBrainzReleaseID = release.ID
+	res.MusicBrainzReleaseGroupID = release.ReleaseGroupID
+
+	if release.Barcode != "" {
+		res.Barcode = release.Barcode
+	}
+
+	if release.Type != "" {
+		res.Type = release.Type
+	}
+
+	// NOTE(patrik): A credit with multiple artists has no single sort
+	// name or artist id, the values of the old artist is removed so they
+	// don't disagree with the new artist
+	if len(release.Artists) == 1 {
+		res.ArtistSort = release.Artists[0].SortName
+		res.MusicBrainzArtistID = release.Artists[0].ID
+	} else {
+		res.ArtistSort = ""
+		res.MusicBrainzArtistID = ""
+	}
+
+	if res.ArtistSort == res.Artist {
+		res.ArtistSort = ""
+	}
+
+	multiDisc := len(release.Media) > 1
+
+	order := sortedTracks(metadata)
+	i := 0
+	for _, medium := range release.Media {
+		for _, track := range medium.Tracks {
+			t := &res.Tracks[order[i]]
+			i++
+
+			t.Num = track.Position
+			t.Name = track.Title
+			t.MusicBrainzRecordingID = track.RecordingID
+
+			if track.ISRC != "" {
+				t.ISRC = track.ISRC
+			}
+
+			t.Disc = 0
+			if multiDisc {
+				t.Disc = medium.Position
+			}
+
+			if release.Year != 0 {
+				t.Year = release.Year
+			}
+
+			artists := track.Artists
+			if len(artists) == 0 {
+				artists = release.Artists
+			}
+
+			if len(artists) > 0 {
+				t.Artist = artists[0].Name
+				t.ArtistSort = artists[0].SortName
+				t.MusicBrainzArtistID = artists[0].ID
+
+				if t.ArtistSort == t.Artist {
+					t.ArtistSort = ""
+				}
+
+				t.Featuring = nil
+				for _, artist := range artists[1:] {
+					t.Featuring = append(t.Featuring, artist.Name)
+				}
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,87 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/nanoteck137/slurpuff/types"
+)
+
+func TestApplyArtists(t *testing.T) {
+	metadata := types.AlbumMetadata{
+		Album:               "Old Album",
+		Artist:              "Old Artist",
+		ArtistSort:          "Artist, Old",
+		ArtistAlt:           "Alt Artist",
+		MusicBrainzArtistID: "old-id",
+		Tracks: []types.TrackMetadata{
+			{Num: 1, Name: "One"},
+		},
+	}
+
+	media := []Medium{
+		{Position: 1, Tracks: []Track{{Position: 1, Title: "One"}}},
+	}
+
+	tests := []struct {
+		name    string
+		release Release
+		sort    string
+		alt     string
+		id      string
+	}{
+		{
+			name: "one artist",
+			release: Release{
+				Artist:  "The Band",
+				Artists: []Artist{{ID: "band-id", Name: "The Band", SortName: "Band, The"}},
+				Media:   media,
+			},
+			sort: "Band, The",
+			id:   "band-id",
+		},
+		{
+			name: "multiple artists",
+			release: Release{
+				Artist: "A & B",
+				Artists: []Artist{
+					{ID: "a-id", Name: "A", SortName: "A"},
+					{ID: "b-id", Name: "B", SortName: "B"},
+				},
+				Media: media,
+			},
+		},
+		{
+			name: "same artist",
+			release: Release{
+				Artist: "Old Artist",
+				Artists: []Artist{
+					{ID: "old-id", Name: "Old Artist", SortName: "Artist, Old"},
+				},
+				Media: media,
+			},
+			sort: "Artist, Old",
+			alt:  "Alt Artist",
+			id:   "old-id",
+		},
+	}
+
+	for _, test := range tests {
+		res := Apply(metadata, test.release)
+
+		if res.Artist != test.release.Artist {
+			t.Errorf("%s: artist %q, expected %q", test.name, res.Artist, test.release.Artist)
+		}
+
+		if res.ArtistSort != test.sort {
+			t.Errorf("%s: artist sort %q, expected %q", test.name, res.ArtistSort, test.sort)
+		}
+
+		if res.ArtistAlt != test.alt {
+			t.Errorf("%s: artist alt %q, expected %q", test.name, res.ArtistAlt, test.alt)
+		}
+
+		if res.MusicBrainzArtistID != test.id {
+			t.Errorf("%s: artist id %q, expected %q", test.name, res.MusicBrainzArtistID, test.id)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package lookup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nanoteck137/slurpuff/types"
+)
+
+// NOTE(patrik): The subset of the release entries inside the MusicBrainz
+// JSON dumps (mbdump/release) that we care about
+type mbArtistCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+	Artist     struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		SortName string `json:"sort-name"`
+	} `json:"artist"`
+}
+
+type mbRelease struct {
+	ID           string           `json:"id"`
+	Title        string           `json:"title"`
+	Date         string           `json:"date"`
+	Barcode      string           `json:"barcode"`
+	ArtistCredit []mbArtistCredit `json:"artist-credit"`
+	ReleaseGroup struct {
+		ID             string   `json:"id"`
+		PrimaryType    string   `json:"primary-type"`
+		SecondaryTypes []string `json:"secondary-types"`
+	} `json:"release-group"`
+	Media []struct {
+		Position int `json:"position"`
+		Tracks   []struct {
+			Position     int              `json:"position"`
+			Title        string           `json:"title"`
+			Length       int              `json:"length"`
+			ArtistCredit []mbArtistCredit `json:"artist-credit"`
+			Recording    struct {
+				ID    string   `json:"id"`
+				ISRCs []string `json:"isrcs"`
+			} `json:"recording"`
+		} `json:"tracks"`
+	} `json:"media"`
+}
+
+func convertArtistCredit(credits []mbArtistCredit) (string, []Artist) {
+	name := strings.Builder{}
+	var artists []Artist
+
+	for _, credit := range credits {
+		name.WriteString(credit.Name)
+		name.WriteString(credit.JoinPhrase)
+
+		artists = append(artists, Artist{
+			ID:       credit.Artist.ID,
+			Name:     credit.Name,
+			SortName: credit.Artist.SortName,
+		})
+	}
+
+	return name.String(), artists
+}
+
+func convertReleaseType(primary string, secondary []string) string {
+	for _, t := range secondary {
+		switch t {
+		case "Compilation":
+			return types.ReleaseTypeCompilation
+		case "Soundtrack":
+			return types.ReleaseTypeSoundtrack
+		case "Live":
+			return types.ReleaseTypeLive
+		}
+	}
+
+	switch primary {
+	case "Album":
+		return types.ReleaseTypeAlbum
+	case "EP":
+		return types.ReleaseTypeEP
+	case "Single":
+		return types.ReleaseTypeSingle
+	}
+
+	return ""
+}
+
+func convertRelease(r mbRelease) Release {
+	artist, artists := convertArtistCredit(r.ArtistCredit)
+
+	year := 0
+	if len(r.Date) >= 4 {
+		year, _ = strconv.Atoi(r.Date[:4])
+	}
+
+	release := Release{
+		ID:             r.ID,
+		ReleaseGroupID: r.ReleaseGroup.ID,
+		Title:          r.Title,
+		Artist:         artist,
+		Artists:        artists,
+		Year:           year,
+		Type:           convertReleaseType(r.ReleaseGroup.PrimaryType, r.ReleaseGroup.SecondaryTypes),
+		Barcode:        r.Barcode,
+	}
+
+	for _, m := range r.Media {
+		medium := Medium{
+			Position: m.Position,
+		}
+
+		for _, t := range m.Tracks {
+			_, trackArtists := convertArtistCredit(t.ArtistCredit)
+
+			// NOTE(patrik): A recording can have multiple ISRCs, we can't
+			// know which one belongs to this release
+			isrc := ""
+			if len(t.Recording.ISRCs) == 1 {
+				isrc = t.Recording.ISRCs[0]
+			}
+
+			medium.Tracks = append(medium.Tracks, Track{
+				Position:    t.Position,
+				Title:       t.Title,
+				Length:      (t.Length + 500) / 1000,
+				RecordingID: t.Recording.ID,
+				ISRC:        isrc,
+				Artists:     trackArtists,
+			})
+		}
+
+		release.Media = append(release.Media, medium)
+	}
+
+	return release
+}
+
+func openMaybeGzip(p string) (io.ReadCloser, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(p) != ".gz" {
+		return f, nil
+	}
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// readLines calls fn for every line, the lines inside the dumps can be
+// larger then what bufio.Scanner can handle
+func readLines(r io.Reader, fn func(line []byte) error) error {
+	reader := bufio.NewReaderSize(r, 1024*1024)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			err := fn(line)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+func DefaultMusicBrainzIndex() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return path.Join(dir, "slurpuff", "musicbrainz.jsonl")
+}
+
+// ImportMusicBrainzDump converts the release dump to the smaller index
+// used by the lookup, returns the number of imported releases
+func ImportMusicBrainzDump(dump, index string) (int, error) {
+	r, err := openMaybeGzip(dump)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	err = os.MkdirAll(path.Dir(index), 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp := index + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	err = readLines(r, func(line []byte) error {
+		var release mbRelease
+		err := json.Unmarshal(line, &release)
+		if err != nil {
+			return fmt.Errorf("%s: release %d: %w", dump, count+1, err)
+		}
+
+		count++
+		return encoder.Encode(convertRelease(release))
+	})
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, os.Rename(tmp, index)
+}
+
+type MusicBrainzDump struct {
+	Index string
+}
+
+func (p *MusicBrainzDump) Name() string {
+	return "musicbrainz-dump"
+}
+
+// NOTE(patrik): The track count is checked before decoding the full line
+// to keep the scan of the index fast
+type releaseHeader struct {
+	Media []struct {
+		Tracks []json.RawMessage `json:"tracks"`
+	} `json:"media"`
+}
+
+func (p *MusicBrainzDump) Search(query Query) ([]Release, error) {
+	f, err := openMaybeGzip(p.Index)
+	if err != nil {
+		return nil, fmt.Errorf("musicbrainz index: %w (import a dump with 'lookup import')", err)
+	}
+	defer f.Close()
+
+	var res []Release
+	err = readLines(f, func(line []byte) error {
+		var header releaseHeader
+		err := json.Unmarshal(line, &header)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, medium := range header.Media {
+			count += len(medium.Tracks)
+		}
+
+		if count != len(query.Durations) {
+			return nil
+		}
+
+		var release Release
+		err = json.Unmarshal(line, &release)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := distance(query, release); !ok {
+			return nil
+		}
+
+		res = append(res, release)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
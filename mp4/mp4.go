@@ -0,0 +1,469 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// NOTE(patrik): Just enough of the MP4 box structure to add the iTunes
+// gapless tag (iTunSMPB) to files written by ffmpeg. ffmpeg stores the
+// encoder delay and padding in the edit list, iTunes and a lot of
+// hardware players only looks at iTunSMPB.
+
+var ErrInvalid = errors.New("invalid mp4 file")
+
+type box struct {
+	typ    string
+	start  int // start of the header
+	header int // size of the header
+	end    int
+}
+
+func (b box) payload() (int, int) {
+	return b.start + b.header, b.end
+}
+
+// readBoxes returns the boxes inside data[start:end]
+func readBoxes(data []byte, start, end int) ([]box, error) {
+	var res []box
+
+	for pos := start; pos < end; {
+		if end-pos < 8 {
+			return nil, ErrInvalid
+		}
+
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		header := 8
+
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			if end-pos < 16 {
+				return nil, ErrInvalid
+			}
+
+			size = int(binary.BigEndian.Uint64(data[pos+8:]))
+			header = 16
+		}
+
+		if size < header || pos+size > end {
+			return nil, ErrInvalid
+		}
+
+		res = append(res, box{
+			typ:    typ,
+			start:  pos,
+			header: header,
+			end:    pos + size,
+		})
+
+		pos += size
+	}
+
+	return res, nil
+}
+
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+
+	return box{}, false
+}
+
+// children returns the child boxes, skip is the size of the fields before
+// the children (full boxes like meta has version and flags)
+func children(data []byte, b box, skip int) ([]box, error) {
+	start, end := b.payload()
+	if end-start < skip {
+		return nil, ErrInvalid
+	}
+
+	return readBoxes(data, start+skip, end)
+}
+
+// findPath follows the path of box types from the boxes
+func findPath(data []byte, boxes []box, path ...string) (box, bool) {
+	var current box
+	for i, typ := range path {
+		b, ok := findBox(boxes, typ)
+		if !ok {
+			return box{}, false
+		}
+
+		current = b
+
+		if i < len(path)-1 {
+			skip := 0
+			if typ == "meta" {
+				skip = 4
+			}
+
+			var err error
+			boxes, err = children(data, b, skip)
+			if err != nil {
+				return box{}, false
+			}
+		}
+	}
+
+	return current, true
+}
+
+// GaplessInfo is the encoder delay and padding in samples and the number
+// of real samples of the audio track
+type GaplessInfo struct {
+	Delay   int64
+	Padding int64
+	Samples int64
+}
+
+// ITunSMPB returns the value of the iTunSMPB tag
+func (g GaplessInfo) ITunSMPB() string {
+	return fmt.Sprintf(" 00000000 %08X %08X %016X 00000000 00000000 00000000 00000000 00000000 00000000 00000000 00000000", g.Delay, g.Padding, g.Samples)
+}
+
+// timescale returns the timescale and duration of a mvhd or mdhd box
+func timescale(data []byte, b box) (int64, int64, error) {
+	start, end := b.payload()
+	if end-start < 4 {
+		return 0, 0, ErrInvalid
+	}
+
+	p := data[start:end]
+	if p[0] == 1 {
+		if len(p) < 4+8+8+4+8 {
+			return 0, 0, ErrInvalid
+		}
+
+		return int64(binary.BigEndian.Uint32(p[20:])), int64(binary.BigEndian.Uint64(p[24:])), nil
+	}
+
+	if len(p) < 4+4+4+4+4 {
+		return 0, 0, ErrInvalid
+	}
+
+	return int64(binary.BigEndian.Uint32(p[12:])), int64(binary.BigEndian.Uint32(p[16:])), nil
+}
+
+func readGaplessInfo(data []byte, moov box) (GaplessInfo, error) {
+	boxes, err := children(data, moov, 0)
+	if err != nil {
+		return GaplessInfo{}, err
+	}
+
+	mvhd, ok := findBox(boxes, "mvhd")
+	if !ok {
+		return GaplessInfo{}, fmt.Errorf("%w: missing mvhd", ErrInvalid)
+	}
+
+	movieScale, _, err := timescale(data, mvhd)
+	if err != nil {
+		return GaplessInfo{}, err
+	}
+
+	trak, ok := findBox(boxes, "trak")
+	if !ok {
+		return GaplessInfo{}, fmt.Errorf("%w: missing trak", ErrInvalid)
+	}
+
+	trakBoxes, err := children(data, trak, 0)
+	if err != nil {
+		return GaplessInfo{}, err
+	}
+
+	mdhd, ok := findPath(data, trakBoxes, "mdia", "mdhd")
+	if !ok {
+		return GaplessInfo{}, fmt.Errorf("%w: missing mdhd", ErrInvalid)
+	}
+
+	mediaScale, mediaDuration, err := timescale(data, mdhd)
+	if err != nil {
+		return GaplessInfo{}, err
+	}
+
+	res := GaplessInfo{
+		Samples: mediaDuration,
+	}
+
+	elst, ok := findPath(data, trakBoxes, "edts", "elst")
+	if !ok || movieScale == 0 {
+		return res, nil
+	}
+
+	start, end := elst.payload()
+	p := data[start:end]
+	if len(p) < 8 || binary.BigEndian.Uint32(p[4:]) == 0 {
+		return res, nil
+	}
+
+	var segmentDuration, mediaTime int64
+	if p[0] == 1 {
+		if len(p) < 8+16 {
+			return GaplessInfo{}, ErrInvalid
+		}
+
+		segmentDuration = int64(binary.BigEndian.Uint64(p[8:]))
+		mediaTime = int64(binary.BigEndian.Uint64(p[16:]))
+	} else {
+		if len(p) < 8+8 {
+			return GaplessInfo{}, ErrInvalid
+		}
+
+		segmentDuration = int64(binary.BigEndian.Uint32(p[8:]))
+		mediaTime = int64(int32(binary.BigEndian.Uint32(p[12:])))
+	}
+
+	if mediaTime < 0 {
+		return res, nil
+	}
+
+	// NOTE(patrik): Only sample accurate when the movie timescale is the
+	// sample rate, the exporter makes ffmpeg write it that way
+	res.Delay = mediaTime
+	res.Samples = segmentDuration * mediaScale / movieScale
+	res.Padding = max(mediaDuration-res.Delay-res.Samples, 0)
+
+	return res, nil
+}
+
+func boxHeader(typ string, size int) []byte {
+	res := make([]byte, 8)
+	binary.BigEndian.PutUint32(res, uint32(size))
+	copy(res[4:], typ)
+	return res
+}
+
+func makeBox(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+
+	res := boxHeader(typ, size)
+	for _, p := range payload {
+		res = append(res, p...)
+	}
+
+	return res
+}
+
+// freeformTag returns a "----" box with the tag
+func freeformTag(mean, name, value string) []byte {
+	fullBox := []byte{0, 0, 0, 0}
+
+	// NOTE(patrik): Data type 1 is UTF-8 text, followed by the locale
+	dataHeader := []byte{0, 0, 0, 1, 0, 0, 0, 0}
+
+	return makeBox("----",
+		makeBox("mean", fullBox, []byte(mean)),
+		makeBox("name", fullBox, []byte(name)),
+		makeBox("data", dataHeader, []byte(value)),
+	)
+}
+
+// insert inserts the box at pos and grows all the parents
+func insert(data []byte, pos int, b []byte, parents []box) ([]byte, error) {
+	for _, parent := range parents {
+		if parent.header != 8 {
+			return nil, fmt.Errorf("%w: large %s box", ErrInvalid, parent.typ)
+		}
+
+		size := binary.BigEndian.Uint32(data[parent.start:])
+		binary.BigEndian.PutUint32(data[parent.start:], size+uint32(len(b)))
+	}
+
+	res := make([]byte, 0, len(data)+len(b))
+	res = append(res, data[:pos]...)
+	res = append(res, b...)
+	res = append(res, data[pos:]...)
+
+	return res, nil
+}
+
+// fixChunkOffsets moves the chunk offsets after pos by delta, needed when
+// the moov box is in front of the media data
+func fixChunkOffsets(data []byte, moov box, pos int, delta int) error {
+	boxes, err := children(data, moov, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, trak := range boxes {
+		if trak.typ != "trak" {
+			continue
+		}
+
+		trakBoxes, err := children(data, trak, 0)
+		if err != nil {
+			return err
+		}
+
+		stbl, ok := findPath(data, trakBoxes, "mdia", "minf", "stbl")
+		if !ok {
+			continue
+		}
+
+		stblBoxes, err := children(data, stbl, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range stblBoxes {
+			start, end := b.payload()
+			if end-start < 8 {
+				continue
+			}
+
+			count := int(binary.BigEndian.Uint32(data[start+4:]))
+			entries := start + 8
+
+			switch b.typ {
+			case "stco":
+				if entries+count*4 > end {
+					return ErrInvalid
+				}
+
+				for i := 0; i < count; i++ {
+					p := entries + i*4
+					offset := binary.BigEndian.Uint32(data[p:])
+					if int(offset) >= pos {
+						binary.BigEndian.PutUint32(data[p:], offset+uint32(delta))
+					}
+				}
+			case "co64":
+				if entries+count*8 > end {
+					return ErrInvalid
+				}
+
+				for i := 0; i < count; i++ {
+					p := entries + i*8
+					offset := binary.BigEndian.Uint64(data[p:])
+					if int(offset) >= pos {
+						binary.BigEndian.PutUint64(data[p:], offset+uint64(delta))
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tag is a freeform iTunes tag (----:com.apple.iTunes:<Name>), used for
+// the tags without an atom of their own
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// WriteTags adds the tags to the file, the iTunSMPB tag is added as well
+// if gapless is set
+func WriteTags(p string, tags []Tag, gapless bool) error {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	top, err := readBoxes(data, 0, len(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+
+	moov, ok := findBox(top, "moov")
+	if !ok {
+		return fmt.Errorf("%s: %w: missing moov", p, ErrInvalid)
+	}
+
+	var tag []byte
+
+	if gapless {
+		info, err := readGaplessInfo(data, moov)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		tag = append(tag, freeformTag("com.apple.iTunes", "iTunSMPB", info.ITunSMPB())...)
+	}
+
+	for _, t := range tags {
+		tag = append(tag, freeformTag("com.apple.iTunes", t.Name, t.Value)...)
+	}
+
+	if len(tag) == 0 {
+		return nil
+	}
+
+	moovBoxes, err := children(data, moov, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+
+	// NOTE(patrik): Create the missing parts of moov/udta/meta/ilst
+	parents := []box{moov}
+	pos := moov.end
+
+	if udta, ok := findBox(moovBoxes, "udta"); ok {
+		parents = append(parents, udta)
+		pos = udta.end
+
+		udtaBoxes, err := children(data, udta, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		if meta, ok := findBox(udtaBoxes, "meta"); ok {
+			parents = append(parents, meta)
+			pos = meta.end
+
+			metaBoxes, err := children(data, meta, 4)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+
+			if ilst, ok := findBox(metaBoxes, "ilst"); ok {
+				parents = append(parents, ilst)
+				pos = ilst.end
+			} else {
+				tag = makeBox("ilst", tag)
+			}
+		} else {
+			tag = makeMeta(tag)
+		}
+	} else {
+		tag = makeBox("udta", makeMeta(tag))
+	}
+
+	data, err = insert(data, pos, tag, parents)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+
+	moov.end += len(tag)
+	err = fixChunkOffsets(data, moov, pos, len(tag))
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+
+	return os.WriteFile(p, data, 0644)
+}
+
+// makeMeta returns a meta box with the ilst containing the tags
+func makeMeta(ilst []byte) []byte {
+	hdlr := makeBox("hdlr",
+		[]byte{0, 0, 0, 0},
+		[]byte{0, 0, 0, 0},
+		[]byte("mdir"),
+		[]byte("appl"),
+		make([]byte, 8),
+		[]byte{0},
+	)
+
+	return makeBox("meta", []byte{0, 0, 0, 0}, hdlr, makeBox("ilst", ilst))
+}
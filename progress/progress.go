@@ -0,0 +1,313 @@
+package progress
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// ModeAuto uses ModeTTY if the output is a terminal, ModePlain
+	// otherwise
+	ModeAuto  = "auto"
+	ModeTTY   = "tty"
+	ModePlain = "plain"
+	ModeNone  = "none"
+)
+
+func IsValidMode(mode string) bool {
+	switch mode {
+	case ModeAuto, ModeTTY, ModePlain, ModeNone:
+		return true
+	}
+
+	return false
+}
+
+// Parse reads the output of ffmpeg -progress and calls fn with the
+// position of the output every time ffmpeg reports it
+func Parse(r io.Reader, fn func(pos time.Duration)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+
+		// NOTE(patrik): out_time_ms is in microseconds as well, ffmpeg
+		// reports N/A before the first frame is written
+		if key != "out_time_us" {
+			continue
+		}
+
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || us < 0 {
+			continue
+		}
+
+		fn(time.Duration(us) * time.Microsecond)
+	}
+
+	return scanner.Err()
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Tracker reports the progress of the running tasks and the overall
+// progress and ETA of all the work
+type Tracker struct {
+	w    io.Writer
+	mode string
+
+	lock sync.Mutex
+
+	tasks []*Task
+
+	// Total duration of the planned work, the started tasks is used if
+	// the work isn't planned
+	planned time.Duration
+	started time.Duration
+	done    time.Duration
+
+	start      time.Time
+	lines      int
+	lastRender time.Time
+}
+
+// New creates a tracker writing to f, ModeAuto picks the mode from f
+func New(f *os.File, mode string) *Tracker {
+	if mode == ModeAuto {
+		mode = ModePlain
+		if isTerminal(f) {
+			mode = ModeTTY
+		}
+	}
+
+	return &Tracker{
+		w:    f,
+		mode: mode,
+	}
+}
+
+// Plan adds the duration of work that is going to be started to the total
+// used for the overall progress
+func (t *Tracker) Plan(duration time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.planned += duration
+}
+
+type Task struct {
+	tracker *Tracker
+
+	name     string
+	duration time.Duration
+	pos      time.Duration
+}
+
+// Start starts a task processing duration of audio
+func (t *Tracker) Start(name string, duration time.Duration) *Task {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	task := &Task{
+		tracker:  t,
+		name:     name,
+		duration: duration,
+	}
+
+	t.tasks = append(t.tasks, task)
+	t.started += duration
+
+	switch t.mode {
+	case ModeTTY:
+		t.render(true)
+	case ModePlain:
+		fmt.Fprintf(t.w, "Processing '%s' (%s)\n", name, formatDuration(duration))
+	}
+
+	return task
+}
+
+// Update sets the position of the task inside the audio
+func (task *Task) Update(pos time.Duration) {
+	t := task.tracker
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	task.pos = min(pos, task.duration)
+
+	if t.mode == ModeTTY {
+		t.render(false)
+	}
+}
+
+// Done finishes the task, err is the reason the task failed
+func (task *Task) Done(err error) {
+	t := task.tracker
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i, other := range t.tasks {
+		if other == task {
+			t.tasks = append(t.tasks[:i], t.tasks[i+1:]...)
+			break
+		}
+	}
+
+	t.done += task.duration
+
+	line := fmt.Sprintf("Done '%s' (%s)", task.name, t.overall())
+	if err != nil {
+		line = fmt.Sprintf("Failed '%s': %v", task.name, err)
+	}
+
+	switch t.mode {
+	case ModeTTY:
+		t.clear()
+		fmt.Fprintln(t.w, line)
+		t.render(true)
+	case ModePlain:
+		fmt.Fprintln(t.w, line)
+	}
+}
+
+// Printf prints the line above the progress display
+func (t *Tracker) Printf(format string, a ...any) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	lines := t.lines
+	t.clear()
+
+	fmt.Fprintf(t.w, format, a...)
+
+	if lines > 0 {
+		t.render(true)
+	}
+}
+
+// Finish removes the progress display
+func (t *Tracker) Finish() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.mode == ModeTTY {
+		t.clear()
+	}
+}
+
+func (t *Tracker) total() time.Duration {
+	return max(t.planned, t.started)
+}
+
+// progress returns the amount of work done, including the progress of
+// the running tasks
+func (t *Tracker) progress() time.Duration {
+	done := t.done
+	for _, task := range t.tasks {
+		done += task.pos
+	}
+
+	return done
+}
+
+func (t *Tracker) overall() string {
+	total := t.total()
+	done := t.progress()
+	if total <= 0 {
+		return "100%"
+	}
+
+	res := fmt.Sprintf("%3d%%", int(done*100/total))
+
+	if done > 0 && done < total {
+		elapsed := time.Since(t.start)
+		eta := time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+		res += ", ETA " + formatDuration(eta)
+	}
+
+	return res
+}
+
+// NOTE(patrik): Redraws the display at most 10 times a second unless
+// forced, the tasks is updated a lot more often
+func (t *Tracker) render(force bool) {
+	if !force && time.Since(t.lastRender) < 100*time.Millisecond {
+		return
+	}
+
+	t.lastRender = time.Now()
+
+	t.clear()
+
+	for _, task := range t.tasks {
+		percent := 0
+		if task.duration > 0 {
+			percent = int(task.pos * 100 / task.duration)
+		}
+
+		fmt.Fprintf(t.w, "%-40s %s %3d%%\n", truncate(task.name, 40), bar(percent, 30), percent)
+	}
+
+	fmt.Fprintf(t.w, "Overall %s\n", t.overall())
+
+	t.lines = len(t.tasks) + 1
+}
+
+// clear removes the lines written by the last render
+func (t *Tracker) clear() {
+	for i := 0; i < t.lines; i++ {
+		fmt.Fprint(t.w, "\x1b[1A\x1b[2K")
+	}
+
+	t.lines = 0
+}
+
+func bar(percent, width int) string {
+	filled := percent * width / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)
+	return string(runes[:n-3]) + "..."
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+
+	h := int(d / time.Hour)
+	m := int(d/time.Minute) % 60
+	s := int(d/time.Second) % 60
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -1,10 +1,13 @@
 package single
 
 import (
+	"fmt"
 	"os"
 	"path"
 
 	"github.com/nanoteck137/slurpuff/album"
+	"github.com/nanoteck137/slurpuff/types"
+	"github.com/nanoteck137/slurpuff/utils"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -22,47 +25,75 @@ type SingleConfig struct {
 	Singles []Single `toml:"singles"`
 }
 
-func Execute(mode, src, dst string) error {
-	// srcDir, _ := cmd.Flags().GetString("src")
-
-	err := os.MkdirAll(dst, 0755)
-	if err != nil {
-		return err
-	}
-
+// ReadConfigs reads the singles.toml inside src and returns an album config
+// for every single
+func ReadConfigs(src string) ([]types.AlbumMetadata, error) {
 	conf := path.Join(src, "singles.toml")
 
 	data, err := os.ReadFile(conf)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var config SingleConfig
 	err = toml.Unmarshal(data, &config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var res []types.AlbumMetadata
 	for _, single := range config.Singles {
-		_ = single
+		year := 0
+		if single.Date != "" {
+			y, err := types.ParseYear(single.Date)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", conf, single.Name, err)
+			}
+
+			year = y
+		}
+
+		file := types.TrackFile{
+			Lossless: single.Filename,
+		}
 
-		albumConfig := album.AlbumConfig{
+		if utils.IsLossyFormatExt(path.Ext(single.Filename)) {
+			file = types.TrackFile{
+				Lossy: single.Filename,
+			}
+		}
+
+		res = append(res, types.AlbumMetadata{
 			Album:    single.Name,
 			Artist:   config.Artist,
+			Type:     types.ReleaseTypeSingle,
 			CoverArt: single.CoverArt,
-			Tracks:   []album.Track{
+			Tracks: []types.TrackMetadata{
 				{
-					Filename:  single.Filename,
 					Num:       1,
 					Name:      single.Name,
-					Date:      single.Date,
+					Year:      year,
 					Tags:      single.Tags,
 					Featuring: single.Featuring,
+					File:      file,
 				},
 			},
-		}
+		})
+	}
+
+	return res, nil
+}
+
+func Execute(opts album.Options, src, dst string) error {
+	// srcDir, _ := cmd.Flags().GetString("src")
+
+	configs, err := ReadConfigs(src)
+	if err != nil {
+		return err
+	}
 
-		err := album.ExecuteConfig(albumConfig, mode, src, dst)
+	for _, config := range configs {
+		err := album.ExecuteConfig(config, opts, src, dst)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,51 @@
+package single
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReadConfigsDate(t *testing.T) {
+	tests := []struct {
+		date string
+		year int
+		err  bool
+	}{
+		{"", 0, false},
+		{"2020", 2020, false},
+		{"2020-05-01", 2020, false},
+		{"2020-05", 2020, false},
+		{"May 2020", 0, true},
+		{"20", 0, true},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+
+		data := "artist = \"Artist\"\n\n[[singles]]\nfilename = \"single.flac\"\nname = \"Single\"\ndate = \"" + test.date + "\"\n"
+
+		err := os.WriteFile(path.Join(dir, "singles.toml"), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		configs, err := ReadConfigs(dir)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.date)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %v", test.date, err)
+			continue
+		}
+
+		if year := configs[0].Tracks[0].Year; year != test.year {
+			t.Errorf("%q: got year %d, expected %d", test.date, year, test.year)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type OldTrackMetadata struct {
 	Filename  string   `toml:"filename"`
 	Num       int      `toml:"num"`
@@ -18,26 +24,252 @@ type OldAlbumMetadata struct {
 	Tracks   []OldTrackMetadata `toml:"tracks"`
 }
 
+// ParseYear returns the year from the start of a date, the old configs
+// stores full dates (2020-05-01) as well as only the year
+func ParseYear(date string) (int, error) {
+	if len(date) < 4 {
+		return 0, fmt.Errorf("invalid date: %s", date)
+	}
+
+	year, err := strconv.Atoi(date[:4])
+	if err != nil || year < 0 {
+		return 0, fmt.Errorf("invalid date: %s", date)
+	}
+
+	if len(date) > 4 && date[4] != '-' {
+		return 0, fmt.Errorf("invalid date: %s", date)
+	}
+
+	return year, nil
+}
+
 type TrackFile struct {
 	Lossless string `toml:"lossless"`
 	Lossy    string `toml:"lossy"`
+
+	// Range of the track inside the lossless file in samples, used for
+	// rips with all the tracks in one file (CUE sheets). End 0 is the end
+	// of the file
+	Start int64 `toml:"start,omitempty"`
+	End   int64 `toml:"end,omitempty"`
+}
+
+// IsSplit reports if the track is only part of the lossless file
+func (f TrackFile) IsSplit() bool {
+	return f.Start != 0 || f.End != 0
+}
+
+// SpectrumCheck is the result of the fake lossless check of the lossless
+// file, Cutoff is the detected frequency cutoff in Hz (0 if none)
+type SpectrumCheck struct {
+	Verdict string `toml:"verdict"`
+	Cutoff  int    `toml:"cutoff,omitempty"`
 }
 
 type TrackMetadata struct {
-	Num       int       `toml:"num"`
-	Name      string    `toml:"name"`
-	Duration  int       `toml:"duration"`
-	Artist    string    `toml:"artist"`
-	Year      int       `toml:"year"`
-	Tags      []string  `toml:"tags"`
-	Genres    []string  `toml:"genres"`
-	Featuring []string  `toml:"featuring"`
-	File      TrackFile `toml:"file,inline"`
+	Num        int       `toml:"num"`
+	Disc       int       `toml:"disc,omitempty"`
+	Name       string    `toml:"name"`
+	NameSort   string    `toml:"name_sort,omitempty"`
+	NameAlt    string    `toml:"name_alt,omitempty"`
+	Duration   int       `toml:"duration"`
+	Artist     string    `toml:"artist"`
+	ArtistSort string    `toml:"artist_sort,omitempty"`
+	ArtistAlt  string    `toml:"artist_alt,omitempty"`
+	Year       int       `toml:"year"`
+	Tags       []string  `toml:"tags"`
+	Genres     []string  `toml:"genres"`
+	Featuring  []string  `toml:"featuring"`
+	File       TrackFile `toml:"file,inline"`
+
+	MusicBrainzRecordingID string `toml:"musicbrainz_recording_id,omitempty"`
+	MusicBrainzArtistID    string `toml:"musicbrainz_artist_id,omitempty"`
+	ISRC                   string `toml:"isrc,omitempty"`
+
+	Spectrum *SpectrumCheck `toml:"spectrum,inline,omitempty"`
+}
+
+// SourceFile returns the file used as the source when exporting, the
+// lossless file is preferred
+func (t *TrackMetadata) SourceFile() string {
+	if t.File.Lossless != "" {
+		return t.File.Lossless
+	}
+
+	return t.File.Lossy
+}
+
+const (
+	ReleaseTypeAlbum       = "album"
+	ReleaseTypeEP          = "ep"
+	ReleaseTypeSingle      = "single"
+	ReleaseTypeCompilation = "compilation"
+	ReleaseTypeSoundtrack  = "soundtrack"
+	ReleaseTypeLive        = "live"
+)
+
+var validReleaseTypes = []string{
+	ReleaseTypeAlbum,
+	ReleaseTypeEP,
+	ReleaseTypeSingle,
+	ReleaseTypeCompilation,
+	ReleaseTypeSoundtrack,
+	ReleaseTypeLive,
+}
+
+func IsValidReleaseType(t string) bool {
+	for _, valid := range validReleaseTypes {
+		if valid == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	ArtworkFront   = "front"
+	ArtworkBack    = "back"
+	ArtworkDisc    = "disc"
+	ArtworkBooklet = "booklet"
+	ArtworkArtist  = "artist"
+)
+
+var validArtworkTypes = []string{
+	ArtworkFront,
+	ArtworkBack,
+	ArtworkDisc,
+	ArtworkBooklet,
+	ArtworkArtist,
+}
+
+func IsValidArtworkType(t string) bool {
+	for _, valid := range validArtworkTypes {
+		if valid == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Artwork is an extra image (or booklet pdf) of the release, the main
+// front cover is still stored in CoverArt
+type Artwork struct {
+	Type string `toml:"type"`
+	Path string `toml:"path"`
 }
 
+// NOTE(patrik): The alternate names is for releases where the original
+// name is in another language/script (romanized or translated name)
 type AlbumMetadata struct {
-	Album    string          `toml:"album"`
-	Artist   string          `toml:"artist"`
-	CoverArt string          `toml:"coverart"`
-	Tracks   []TrackMetadata `toml:"tracks"`
+	Album      string `toml:"album"`
+	AlbumSort  string `toml:"album_sort,omitempty"`
+	AlbumAlt   string `toml:"album_alt,omitempty"`
+	Artist     string `toml:"artist"`
+	ArtistSort string `toml:"artist_sort,omitempty"`
+	ArtistAlt  string `toml:"artist_alt,omitempty"`
+	Type       string `toml:"type"`
+	CoverArt   string `toml:"coverart"`
+
+	// Encode the tracks so they play back without gaps between them (live
+	// albums, DJ mixes)
+	Gapless bool `toml:"gapless,omitempty"`
+
+	MusicBrainzReleaseID      string `toml:"musicbrainz_release_id,omitempty"`
+	MusicBrainzReleaseGroupID string `toml:"musicbrainz_release_group_id,omitempty"`
+	MusicBrainzArtistID       string `toml:"musicbrainz_artist_id,omitempty"`
+	DiscogsReleaseID          string `toml:"discogs_release_id,omitempty"`
+	Barcode                   string `toml:"barcode,omitempty"`
+
+	Artwork []Artwork `toml:"artwork,omitempty"`
+
+	Tracks []TrackMetadata `toml:"tracks"`
+}
+
+// Files returns all the files referenced by the album relative to the
+// album directory
+func (m *AlbumMetadata) Files() []string {
+	var res []string
+	seen := make(map[string]bool)
+
+	add := func(name string) {
+		// NOTE(patrik): Split tracks shares the same file
+		if name != "" && !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+
+	for _, track := range m.Tracks {
+		add(track.File.Lossless)
+		add(track.File.Lossy)
+	}
+
+	add(m.CoverArt)
+
+	for _, artwork := range m.Artwork {
+		add(artwork.Path)
+	}
+
+	return res
+}
+
+// NOTE(patrik): Albums without a type is treated as a normal album
+func (m *AlbumMetadata) ReleaseType() string {
+	if m.Type == "" {
+		return ReleaseTypeAlbum
+	}
+
+	return m.Type
+}
+
+func (m *AlbumMetadata) IsCompilation() bool {
+	return m.Type == ReleaseTypeCompilation
+}
+
+// Year returns the earliest year set on the tracks
+func (m *AlbumMetadata) Year() int {
+	year := 0
+	for _, track := range m.Tracks {
+		if track.Year != 0 && (year == 0 || track.Year < year) {
+			year = track.Year
+		}
+	}
+
+	return year
+}
+
+var sortArticles = []string{"The ", "A ", "An "}
+
+// DefaultSortName moves a leading english article to the end of the name,
+// "The Beatles" becomes "Beatles, The"
+func DefaultSortName(name string) string {
+	for _, article := range sortArticles {
+		if len(name) > len(article) && strings.EqualFold(name[:len(article)], article) {
+			return name[len(article):] + ", " + strings.TrimSpace(name[:len(article)])
+		}
+	}
+
+	return name
+}
+
+// NOTE(patrik): Written to the exported artist and album folders so
+// importers (dwebble) don't need to guess from the folder names
+const FolderMetadataFile = "metadata.toml"
+
+type ArtistFolderMetadata struct {
+	Name     string `toml:"name"`
+	SortName string `toml:"sort_name"`
+	AltName  string `toml:"alt_name,omitempty"`
+}
+
+type AlbumFolderMetadata struct {
+	Name     string `toml:"name"`
+	SortName string `toml:"sort_name"`
+	AltName  string `toml:"alt_name,omitempty"`
+	Artist   string `toml:"artist"`
+	Year     int    `toml:"year,omitempty"`
+	Type     string `toml:"type"`
+	CoverArt string `toml:"coverart,omitempty"`
 }
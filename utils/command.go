@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Stderr is the captured stderr of a command
+type Stderr struct {
+	cmd *exec.Cmd
+	buf bytes.Buffer
+}
+
+// CaptureStderr captures the stderr of the command, Stderr.Done is called
+// with the result of the command
+func CaptureStderr(cmd *exec.Cmd) *Stderr {
+	s := &Stderr{cmd: cmd}
+	cmd.Stderr = &s.buf
+
+	return s
+}
+
+func (s *Stderr) String() string {
+	return strings.TrimSpace(s.buf.String())
+}
+
+// Done logs the output at debug level and adds the last line of the output
+// to the error if the command failed
+func (s *Stderr) Done(err error) error {
+	msg := s.String()
+
+	slog.Debug("Command done", "cmd", path.Base(s.cmd.Path), "args", s.cmd.Args[1:], "stderr", msg, "err", err)
+
+	if err != nil && msg != "" {
+		lines := strings.Split(msg, "\n")
+		return fmt.Errorf("%w: %s", err, lines[len(lines)-1])
+	}
+
+	return err
+}
+
+// RunFFmpeg runs ffmpeg with the output captured
+func RunFFmpeg(args ...string) error {
+	cmd := exec.Command("ffmpeg", args...)
+	stderr := CaptureStderr(cmd)
+
+	return stderr.Done(cmd.Run())
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+var frontCoverNames = []string{"cover", "folder", "front"}
+
+// NOTE(patrik): Names of the other scans usually found together with the
+// cover
+var otherArtworkNames = []string{"back", "booklet", "disc", "cd", "inlay", "inside", "tray", "scan", "artist"}
+
+type coverCandidate struct {
+	name  string
+	score float64
+}
+
+// nameScore scores the name and location of the image, only images with a
+// positive score is accepted as the front cover
+func nameScore(name string) float64 {
+	score := 0.0
+
+	base := strings.ToLower(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+
+	for _, n := range frontCoverNames {
+		if base == n {
+			score += 100
+			break
+		} else if strings.Contains(base, n) {
+			score += 50
+			break
+		}
+	}
+
+	for _, n := range otherArtworkNames {
+		if strings.Contains(base, n) {
+			score -= 50
+			break
+		}
+	}
+
+	// NOTE(patrik): Images inside subdirectories is usually scans
+	if !strings.Contains(name, "/") {
+		score += 20
+	}
+
+	return score
+}
+
+func scoreCoverImage(dir, name string) float64 {
+	score := nameScore(name)
+
+	f, err := os.Open(path.Join(dir, name))
+	if err != nil {
+		return score
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil || config.Width == 0 || config.Height == 0 {
+		return score
+	}
+
+	w, h := float64(config.Width), float64(config.Height)
+
+	// Square images gets 30 points, less the further from square
+	aspect := math.Min(w, h) / math.Max(w, h)
+	score += 30 * math.Max(0, 1-(1-aspect)*4)
+
+	// Up to 30 points for the size, full points at 1000x1000
+	score += 30 * math.Min(1, math.Sqrt(w*h)/1000)
+
+	return score
+}
+
+// findFiles returns the files inside dir and the directories one level
+// down accepted by valid, the names is relative to dir
+func findFiles(dir string, valid func(ext string) bool) []string {
+	var res []string
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	for _, entry := range entries {
+		if entry.Name()[0] == '.' {
+			continue
+		}
+
+		if !entry.IsDir() {
+			if valid(filepath.Ext(entry.Name())) {
+				res = append(res, entry.Name())
+			}
+
+			continue
+		}
+
+		subEntries, err := os.ReadDir(path.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, sub := range subEntries {
+			if !sub.IsDir() && sub.Name()[0] != '.' && valid(filepath.Ext(sub.Name())) {
+				res = append(res, entry.Name()+"/"+sub.Name())
+			}
+		}
+	}
+
+	return res
+}
+
+// RankCoverImages returns the images inside dir and the directories one
+// level down ordered by how likely they are to be the front cover, the
+// names is relative to dir
+func RankCoverImages(dir string) []string {
+	var candidates []coverCandidate
+
+	for _, name := range findFiles(dir, IsValidCoverExt) {
+		candidates = append(candidates, coverCandidate{
+			name:  name,
+			score: scoreCoverImage(dir, name),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	res := make([]string, len(candidates))
+	for i, c := range candidates {
+		res[i] = c.name
+	}
+
+	return res
+}
+
+// FindArtworkFiles returns the images and pdf files inside dir and the
+// directories one level down, the names is relative to dir
+func FindArtworkFiles(dir string) []string {
+	return findFiles(dir, func(ext string) bool {
+		return IsValidCoverExt(ext) || strings.EqualFold(ext, ".pdf")
+	})
+}
+
+// FindBestImage returns the image most likely to be the front cover, empty
+// if no image looks like the front cover
+func FindBestImage(dir string) string {
+	// NOTE(patrik): The size and aspect can't make up for the name, a
+	// large square booklet scan inside a subdirectory is still not the
+	// cover. The image needs a front cover name or to be in the top level
+	// directory without being named like the other artwork.
+	for _, name := range RankCoverImages(dir) {
+		if nameScore(name) > 0 {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// ExtractEmbeddedCover writes the picture embedded inside the file to
+// dir/cover.<ext>, returns the name of the written file
+func ExtractEmbeddedCover(p string, picture *Picture, dir string) (string, error) {
+	ext := ".png"
+	codecArgs := []string{"-c:v", "png"}
+
+	switch picture.Codec {
+	case "mjpeg":
+		ext = ".jpg"
+		codecArgs = []string{"-c:v", "copy"}
+	case "png":
+		codecArgs = []string{"-c:v", "copy"}
+	}
+
+	name := "cover" + ext
+
+	args := []string{"-y", "-v", "error", "-i", p, "-map", "0:" + strconv.Itoa(picture.Index), "-frames:v", "1"}
+	args = append(args, codecArgs...)
+	args = append(args, "-f", "image2", path.Join(dir, name))
+
+	err := RunFFmpeg(args...)
+	if err != nil {
+		return "", fmt.Errorf("%s: extracting cover: %w", p, err)
+	}
+
+	return name, nil
+}
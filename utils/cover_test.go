@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeTestImage(t *testing.T, p string, w, h int) {
+	t.Helper()
+
+	err := os.MkdirAll(path.Dir(p), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = png.Encode(f, image.NewGray(image.Rect(0, 0, w, h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindBestImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []string
+		best   string
+	}{
+		{
+			name:   "front cover name",
+			images: []string{"back.png", "cover.png", "Scans/booklet01.png"},
+			best:   "cover.png",
+		},
+		{
+			name:   "front cover inside scans",
+			images: []string{"Scans/booklet01.png", "Scans/front.png"},
+			best:   "Scans/front.png",
+		},
+		{
+			name:   "top level image",
+			images: []string{"Scans/booklet01.png", "image.png"},
+			best:   "image.png",
+		},
+		{
+			name:   "only scans",
+			images: []string{"Scans/booklet01.png"},
+			best:   "",
+		},
+		{
+			name:   "only other artwork",
+			images: []string{"back.png", "Scans/disc.png", "Scans/page.png"},
+			best:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// NOTE(patrik): The larger square image should never win
+			// because of the size
+			for i, name := range test.images {
+				size := 100
+				if i == 0 {
+					size = 1000
+				}
+
+				writeTestImage(t, path.Join(dir, name), size, size)
+			}
+
+			best := FindBestImage(dir)
+			if best != test.best {
+				t.Errorf("got %q, expected %q", best, test.best)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
 	"path"
 	"regexp"
@@ -13,9 +12,10 @@ import (
 
 func RunFFprobe(args ...string) ([]byte, error) {
 	cmd := exec.Command("ffprobe", args...)
-	cmd.Stderr = os.Stderr
+	stderr := CaptureStderr(cmd)
 
 	data, err := cmd.Output()
+	err = stderr.Done(err)
 	if err != nil {
 		return nil, err
 	}
@@ -37,8 +37,9 @@ type FileResult struct {
 	Number int
 	Name   string
 
-	Duration int
-	Tags     map[string]string
+	Duration   int
+	SampleRate int
+	Tags       map[string]string
 }
 
 type probeFormat struct {
@@ -63,6 +64,13 @@ type probeStream struct {
 
 	Duration string `json:"duration"`
 
+	// Audio
+	BitRate          string `json:"bit_rate"`
+	SampleRate       string `json:"sample_rate"`
+	Channels         int    `json:"channels"`
+	BitsPerRawSample string `json:"bits_per_raw_sample"`
+	BitsPerSample    int    `json:"bits_per_sample"`
+
 	// Video
 	Width  int `json:"width"`
 	Height int `json:"height"`
@@ -143,6 +151,50 @@ var test2 = regexp.MustCompile(`track(\d+).+`)
 type Info struct {
 	Tags     map[string]string
 	Duration int
+
+	// Audio stream properties, BitRate is in bits per second and is 0 if
+	// ffprobe doesn't report one
+	Codec         string
+	BitRate       int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+
+	// Picture embedded inside the file, nil if the file has none
+	Picture *Picture
+}
+
+type Picture struct {
+	// Index of the attached picture stream
+	Index  int
+	Codec  string
+	Width  int
+	Height int
+}
+
+var losslessCodecs = []string{
+	"flac",
+	"alac",
+	"wavpack",
+	"ape",
+	"tta",
+	"mlp",
+	"truehd",
+}
+
+// IsLossless reports if the audio codec is lossless
+func (i *Info) IsLossless() bool {
+	if strings.HasPrefix(i.Codec, "pcm_") {
+		return true
+	}
+
+	for _, codec := range losslessCodecs {
+		if i.Codec == codec {
+			return true
+		}
+	}
+
+	return false
 }
 
 func GetInfo(filepath string) (Info, error) {
@@ -165,25 +217,48 @@ func GetInfo(filepath string) (Info, error) {
 		tags = convertMapKeysToLowercase(probe.Format.Tags)
 	}
 
-	duration := 0
+	info := Info{}
 	for _, s := range probe.Streams {
+		if s.CodecType == "video" && s.Disposition.AttachedPic == 1 && info.Picture == nil {
+			info.Picture = &Picture{
+				Index:  s.Index,
+				Codec:  s.CodecName,
+				Width:  s.Width,
+				Height: s.Height,
+			}
+		}
+
 		if s.CodecType == "audio" {
 			dur, err := strconv.ParseFloat(s.Duration, 32)
 			if err != nil {
 				return Info{}, err
 			}
 
-			duration = int(dur)
+			info.Duration = int(dur)
 			if ext == ".opus" {
 				tags = convertMapKeysToLowercase(s.Tags)
 			}
+
+			info.Codec = s.CodecName
+			info.Channels = s.Channels
+			info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+			info.BitsPerSample, _ = strconv.Atoi(s.BitsPerRawSample)
+			if info.BitsPerSample == 0 {
+				info.BitsPerSample = s.BitsPerSample
+			}
+
+			// NOTE(patrik): Some containers (ogg) only report the bit rate
+			// for the whole file
+			info.BitRate, _ = strconv.Atoi(s.BitRate)
+			if info.BitRate == 0 {
+				info.BitRate, _ = strconv.Atoi(probe.Format.BitRate)
+			}
 		}
 	}
 
-	return Info{
-		Tags:     tags,
-		Duration: duration,
-	}, nil
+	info.Tags = tags
+
+	return info, nil
 }
 
 func CheckFile(filepath string) (FileResult, error) {
@@ -206,11 +281,12 @@ func CheckFile(filepath string) (FileResult, error) {
 		}
 
 		return FileResult{
-			Path:     filepath,
-			Number:   num,
-			Name:     "",
-			Duration: info.Duration,
-			Tags:     info.Tags,
+			Path:       filepath,
+			Number:     num,
+			Name:       "",
+			Duration:   info.Duration,
+			SampleRate: info.SampleRate,
+			Tags:       info.Tags,
 		}, nil
 	} else {
 		num, err := strconv.Atoi(string(res[1]))
@@ -220,11 +296,12 @@ func CheckFile(filepath string) (FileResult, error) {
 
 		name := string(res[2])
 		return FileResult{
-			Path:     filepath,
-			Number:   num,
-			Name:     name,
-			Duration: info.Duration,
-			Tags:     info.Tags,
+			Path:       filepath,
+			Number:     num,
+			Name:       name,
+			Duration:   info.Duration,
+			SampleRate: info.SampleRate,
+			Tags:       info.Tags,
 		}, nil
 	}
 }
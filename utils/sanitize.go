@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/flytam/filenamify"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	SanitizePosix     = "posix"
+	SanitizeWindows   = "windows"
+	SanitizeFat32Safe = "fat32-safe"
+	SanitizeAsciiOnly = "ascii-only"
+)
+
+const DefaultSanitizeProfile = SanitizeWindows
+
+// NOTE(patrik): Most filesystems limit a single name to 255 bytes (ext4) or
+// 255 UTF-16 code units (NTFS, FAT32, exFAT), the limit is kept at the 100
+// filenamify used before the profiles so already exported names is the same
+// and the full paths stays short
+const maxNameLength = 100
+
+var sanitizeProfiles = []string{
+	SanitizePosix,
+	SanitizeWindows,
+	SanitizeFat32Safe,
+	SanitizeAsciiOnly,
+}
+
+func IsValidSanitizeProfile(profile string) bool {
+	for _, valid := range sanitizeProfiles {
+		if valid == profile {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsCaseInsensitiveProfile reports if names that only differ in case
+// should be treated as the same name
+func IsCaseInsensitiveProfile(profile string) bool {
+	return profile != SanitizePosix
+}
+
+// NameKey returns the key used to detect colliding names for the profile
+func NameKey(name, profile string) string {
+	if IsCaseInsensitiveProfile(profile) {
+		return strings.ToLower(name)
+	}
+
+	return name
+}
+
+var posixReservedRegex = regexp.MustCompile("[/\x00]")
+var windowsReservedNamesRegex = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\..*)?$`)
+
+var transliterations = map[rune]string{
+	'ß': "ss",
+	'æ': "ae", 'Æ': "AE",
+	'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D",
+	'þ': "th", 'Þ': "Th",
+	'ł': "l", 'Ł': "L",
+	'ı': "i",
+	'‘': "'", '’': "'", '‚': "'",
+	'“': "\"", '”': "\"", '„': "\"",
+	'–': "-", '—': "-", '―': "-",
+	'…': "...",
+	'×': "x",
+	'·': "-",
+	'～': "~", '〜': "~",
+	'　': " ",
+}
+
+// Transliterate converts name into plain ASCII, characters without a known
+// ASCII form is replaced with '_'
+func Transliterate(name string) string {
+	b := strings.Builder{}
+
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// NOTE(patrik): Drop the accents left over from the
+			// decomposition
+		default:
+			if s, exists := transliterations[r]; exists {
+				b.WriteString(s)
+			} else if unicode.IsSpace(r) {
+				b.WriteByte(' ')
+			} else {
+				b.WriteByte('_')
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func nameLength(name, profile string) int {
+	if profile == SanitizePosix {
+		return len(name)
+	}
+
+	return len(utf16.Encode([]rune(name)))
+}
+
+// truncateName shortens name to fit the profile limit while keeping ext
+// intact
+func truncateName(name, ext, profile string) string {
+	if nameLength(name, profile) <= maxNameLength {
+		return name
+	}
+
+	stem := []rune(strings.TrimSuffix(name, ext))
+	for len(stem) > 0 && nameLength(string(stem)+ext, profile) > maxNameLength {
+		stem = stem[:len(stem)-1]
+	}
+
+	return strings.TrimSpace(string(stem)) + ext
+}
+
+// SafeNameWithProfile makes name safe to use as a file or folder name,
+// ext is kept when the name needs to be truncated
+func SafeNameWithProfile(name, ext, profile string) (string, error) {
+	res := name
+
+	switch profile {
+	case SanitizePosix:
+		res = posixReservedRegex.ReplaceAllString(res, "")
+	case SanitizeWindows, SanitizeFat32Safe, SanitizeAsciiOnly:
+		if profile == SanitizeAsciiOnly {
+			res = Transliterate(res)
+		}
+
+		if profile == SanitizeFat32Safe {
+			// NOTE(patrik): Some devices can't handle characters outside
+			// of the BMP (emojis)
+			res = strings.Map(func(r rune) rune {
+				if r > 0xffff {
+					return -1
+				}
+
+				return r
+			}, res)
+		}
+
+		var err error
+		res, err = filenamify.FilenamifyV2(res, func(options *filenamify.Options) {
+			options.Replacement = ""
+			// NOTE(patrik): We handle the length ourself
+			options.MaxLength = len(res) + 1
+		})
+		if err != nil {
+			return "", err
+		}
+
+		// NOTE(patrik): Windows silently strips trailing dots and spaces
+		res = strings.TrimRight(res, ". ")
+
+		if windowsReservedNamesRegex.MatchString(res) {
+			res = "_" + res
+		}
+	default:
+		return "", fmt.Errorf("unknown sanitize profile: %s", profile)
+	}
+
+	res = strings.TrimSpace(res)
+	res = truncateName(res, ext, profile)
+
+	if res == "" || res == "." || res == ".." {
+		return "", fmt.Errorf("'%s' is empty after sanitizing (%s)", name, profile)
+	}
+
+	return res, nil
+}
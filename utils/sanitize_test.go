@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeNameWithProfileLength(t *testing.T) {
+	name := strings.Repeat("a", 150) + ".flac"
+
+	for _, profile := range sanitizeProfiles {
+		res, err := SafeNameWithProfile(name, ".flac", profile)
+		if err != nil {
+			t.Fatalf("%s: %v", profile, err)
+		}
+
+		if len(res) != maxNameLength {
+			t.Errorf("%s: got length %d, expected %d", profile, len(res), maxNameLength)
+		}
+
+		if !strings.HasSuffix(res, ".flac") {
+			t.Errorf("%s: extension lost: %q", profile, res)
+		}
+	}
+
+	res, err := SafeNameWithProfile("Short Name", "", DefaultSanitizeProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "Short Name" {
+		t.Errorf("got %q, expected %q", res, "Short Name")
+	}
+}
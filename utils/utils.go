@@ -3,11 +3,8 @@ package utils
 import (
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
 
-	"github.com/flytam/filenamify"
 	"github.com/nanoteck137/parasect"
 )
 
@@ -53,13 +50,16 @@ var validCoverExts []string = []string{
 	"png",
 	"jpg",
 	"jpeg",
+	"webp",
+	"avif",
+	"bmp",
+	"gif",
 }
 
 func IsValidCoverExt(ext string) bool {
 	return parasect.IsValidExt(validCoverExts, ext)
 }
 
-
 var lossyFormatExts = []string{
 	"opus",
 	"mp3",
@@ -68,26 +68,3 @@ var lossyFormatExts = []string{
 func IsLossyFormatExt(ext string) bool {
 	return parasect.IsValidExt(lossyFormatExts, ext)
 }
-
-func SafeName(name string) (string, error) {
-	replacementSpace := func(options *filenamify.Options) { 
-		options.Replacement = "" 
-	}
-
-	return filenamify.FilenamifyV2(name, replacementSpace)
-}
-
-func FindFirstValidImage(dir string) string {
-	found := ""
-
-	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
-		if d.Name()[0] != '.' && IsValidCoverExt(filepath.Ext(p)) {
-			found = d.Name()
-			return filepath.SkipAll
-		}
-
-		return nil
-	})
-
-	return found
-}